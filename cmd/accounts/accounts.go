@@ -0,0 +1,150 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/cli/cli/v2/pkg/cmdutil"
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/majikmate/gh-mmc/pkg/ghapi"
+	"github.com/majikmate/gh-mmc/pkg/mmc"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdAccounts(f *cmdutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "accounts",
+		Short: "Work with the accounts file of a classroom",
+		Long: heredoc.Doc(`
+
+			Work with the accounts file the classroom is initialized from.
+
+			The accounts file is looked up in the current directory the same way
+			as by gh mmc init.`),
+	}
+
+	cmd.AddCommand(NewCmdAccountsValidate(f))
+
+	return cmd
+}
+
+func NewCmdAccountsValidate(f *cmdutil.Factory) *cobra.Command {
+	var roster mmc.RosterConfig
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the accounts file against GitHub",
+		Long: heredoc.Doc(`
+
+			Validates the accounts file in the current directory before or after the
+			classroom is initialized with it.
+
+			This command reports:
+			- Empty rows and rows with a missing Name, Email or GitHub User
+			- Emails not matching the format firstname.lastname@domain, which is
+			  used to name the student folders
+			- Duplicate emails and GitHub users
+			- GitHub users that do not exist on GitHub, or whose spelling differs
+			  from the GitHub login, as repositories could not be mapped to them
+
+			The accounts file is read with the roster settings stored in the classroom
+			metadata, if any, unless overridden by flags.
+
+			The command exits with a nonzero exit code if problems are found.`),
+		Example: heredoc.Doc(`
+			$ gh mmc accounts validate
+
+			# Skip the lookup of GitHub users
+			$ gh mmc accounts validate --offline`),
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := mmc.LoadClassroom()
+			if err != nil {
+				if !errors.Is(err, mmc.ErrClassroomNotFound) {
+					mmc.Fatal(err)
+				}
+			} else {
+				roster = roster.WithDefaults(c.Roster)
+			}
+
+			as, err := mmc.ReadAccounts(roster)
+			if err != nil {
+				mmc.Fatal(fmt.Errorf("failed to read accounts: %v", err))
+			}
+
+			fmt.Printf("Validating %d accounts...\n", len(as))
+
+			issues := mmc.ValidateAccounts(as)
+
+			if !offline {
+				client, err := api.DefaultRESTClient()
+				if err != nil {
+					mmc.Fatal(fmt.Errorf("failed to create gh client: %v", err))
+				}
+
+				for i, a := range as {
+					if !mmc.IsValidGithubLogin(a.GithubUser) {
+						// already reported as missing or invalid
+						continue
+					}
+
+					user, err := ghapi.GetUser(client, a.GithubUser)
+					if err != nil {
+						msg := fmt.Sprintf("failed to look up GitHub user %s: %v", a.GithubUser, err)
+						if errors.Is(err, ghapi.ErrUserNotFound) {
+							msg = fmt.Sprintf("GitHub user %s does not exist", a.GithubUser)
+						}
+						issues = append(issues, mmc.AccountIssue{Row: mmc.AccountRow(i), Account: a, Message: msg})
+						continue
+					}
+
+					if user.Login != a.GithubUser {
+						issues = append(issues, mmc.AccountIssue{
+							Row:     mmc.AccountRow(i),
+							Account: a,
+							Message: fmt.Sprintf("GitHub user %s is spelled %s on GitHub", a.GithubUser, user.Login),
+						})
+					}
+					if user.Type != "" && user.Type != "User" {
+						issues = append(issues, mmc.AccountIssue{
+							Row:     mmc.AccountRow(i),
+							Account: a,
+							Message: fmt.Sprintf("GitHub user %s is not a personal account but of type %s", a.GithubUser, user.Type),
+						})
+					}
+				}
+			}
+
+			if len(issues) == 0 {
+				fmt.Printf("\nAll %d accounts are valid.\n", len(as))
+				return
+			}
+
+			sort.SliceStable(issues, func(i, j int) bool {
+				return issues[i].Row < issues[j].Row
+			})
+
+			fmt.Printf("\n%d problems found:\n", len(issues))
+			for _, issue := range issues {
+				name := issue.Account.Name
+				if name == "" {
+					name = "-"
+				}
+				fmt.Printf("  Row %-4d %-30s %s\n", issue.Row, name, issue.Message)
+			}
+			fmt.Println()
+
+			mmc.Fatal(fmt.Errorf("accounts file is invalid: %d problems found", len(issues)))
+		},
+	}
+
+	cmd.Flags().BoolVar(&offline, "offline", false, "skip the lookup of GitHub users")
+	cmd.Flags().StringVar(&roster.Sheet, "sheet", "", "name of the sheet containing the accounts (defaults to Sheet1 or the first sheet)")
+	cmd.Flags().StringVar(&roster.NameColumn, "name-column", "", "header of the column containing the full name of the student")
+	cmd.Flags().StringVar(&roster.EmailColumn, "email-column", "", "header of the column containing the email address of the student")
+	cmd.Flags().StringVar(&roster.GithubUserColumn, "github-user-column", "", "header of the column containing the GitHub username of the student")
+
+	return cmd
+}
@@ -33,6 +33,8 @@ func NewCmdCheck(f *cmdutil.Factory) *cobra.Command {
 	var orderBy string
 	var filterStudent string
 	var filterAssignment string
+	var includeInactive bool
+	var allClassrooms bool
 
 	cmd := &cobra.Command{
 		Use:   "check",
@@ -47,6 +49,12 @@ func NewCmdCheck(f *cmdutil.Factory) *cobra.Command {
 			- Find maximum similarity for each file pair
 			- Display results per assignment showing potential plagiarism
 			- Highlight similarities above the threshold
+			- Skip folders of group assignments whose teams share members, as
+			  recorded by gh mmc pull in the assignment folder
+			- Skip folders of dropped and auditing students, unless
+			  --include-inactive is set
+			- Check every assignment folder of all classrooms below the workspace
+			  folder and print a combined summary, if --all-classrooms is set
 
 			Files are normalized before comparison by:
 			- Removing empty lines and all comments (full-line and inline)
@@ -63,7 +71,10 @@ func NewCmdCheck(f *cmdutil.Factory) *cobra.Command {
 			$ gh mmc check -e .css -t 80
 
 			# Check JavaScript files
-			$ gh mmc check -e .js -t 75`),
+			$ gh mmc check -e .js -t 75
+
+			# Check HTML files of all classrooms of the workspace
+			$ gh mmc check --all-classrooms`),
 		Run: func(cmd *cobra.Command, args []string) {
 			startingDir, err := os.Getwd()
 			if err != nil {
@@ -73,40 +84,6 @@ func NewCmdCheck(f *cmdutil.Factory) *cobra.Command {
 				_ = os.Chdir(startingDir)
 			}()
 
-			c, err := mmc.LoadClassroom()
-			if err != nil {
-				mmc.Fatal(err)
-			}
-
-			// Try to find assignment folder first (module-html-css level with students)
-			// If not found, try classroom folder
-			var searchPath string
-			assignmentFolder, err := mmc.FindAssignmentFolder()
-			if err == nil {
-				// We're in or below an assignment folder - use it as the search path
-				searchPath = assignmentFolder
-				err = os.Chdir(assignmentFolder)
-				if err != nil {
-					mmc.Fatal(fmt.Errorf("failed to change to assignment directory: %v", err))
-				}
-			} else {
-				// Not in assignment, try classroom folder
-				classroomFolder, err := mmc.FindClassroomFolder()
-				if err != nil {
-					mmc.Fatal("No classroom or assignment found. Run `gh mmc init` to initialize a classroom folder or change to a classroom/assignment folder.")
-				}
-				searchPath = classroomFolder
-				err = os.Chdir(classroomFolder)
-				if err != nil {
-					mmc.Fatal(fmt.Errorf("failed to change to classroom directory: %v", err))
-				}
-			}
-
-			// Determine starter folder name from classroom
-			if starterFolder == "" {
-				starterFolder = c.Classroom.Name
-			}
-
 			// Ensure file extensions start with a dot
 			for i, ext := range fileExtensions {
 				if ext != "" && !strings.HasPrefix(ext, ".") {
@@ -114,50 +91,38 @@ func NewCmdCheck(f *cmdutil.Factory) *cobra.Command {
 				}
 			}
 
-			if verbose {
-				fmt.Printf("Checking classroom: %s\n", c.Classroom.Name)
-				fmt.Printf("Search path: %s\n", searchPath)
-				fmt.Printf("File extensions: %v\n", fileExtensions)
-				fmt.Printf("Threshold: %.0f%%\n", threshold)
-				if len(ignoreFiles) > 0 {
-					fmt.Printf("Ignoring files: %v\n", ignoreFiles)
-				}
-				fmt.Println()
+			// Validate orderBy parameter
+			if orderBy != orderByStudent && orderBy != orderByAssignment {
+				mmc.Fatal(fmt.Errorf("invalid order-by value: %s. Must be '%s' or '%s'", orderBy, orderByStudent, orderByAssignment))
 			}
 
-			// Run the comparison
-			result, err := similarity.CompareAssignments(searchPath, fileExtensions, starterFolder, ignoreFiles, verbose)
-			if err != nil {
-				mmc.Fatal(fmt.Errorf("failed to compare assignments: %v", err))
+			opts := checkOptions{
+				fileExtensions:   fileExtensions,
+				threshold:        threshold,
+				starterFolder:    starterFolder,
+				ignoreFiles:      ignoreFiles,
+				showDiff:         showDiff,
+				verbose:          verbose,
+				orderBy:          orderBy,
+				filterStudent:    filterStudent,
+				filterAssignment: filterAssignment,
+				includeInactive:  includeInactive,
+				allClassrooms:    allClassrooms,
 			}
 
-			// Get sorted list of students
-			students := make([]string, 0, len(result.Results))
-			for student := range result.Results {
-				students = append(students, student)
+			if showDiff && !f.IOStreams.CanPrompt() {
+				mmc.Fatal(fmt.Errorf("%w: --diff is interactive, drop it to print the results only", mmc.ErrNoPrompt))
 			}
-			sort.Strings(students)
 
-			if len(students) == 0 {
-				fmt.Println("No student submissions found.")
+			if allClassrooms {
+				if showDiff {
+					mmc.Fatal("--diff cannot be used with --all-classrooms")
+				}
+				checkAllClassrooms(opts)
 				return
 			}
 
-			// Sort assignments
-			sort.Strings(result.Assignments)
-
-			// Validate orderBy parameter
-			if orderBy != orderByStudent && orderBy != orderByAssignment {
-				mmc.Fatal(fmt.Errorf("invalid order-by value: %s. Must be '%s' or '%s'", orderBy, orderByStudent, orderByAssignment))
-			}
-
-			// Print overall summary and get pairs
-			pairs := printOverallSummary(students, result, threshold, fileExtensions, ignoreFiles, c.Classroom.Name, orderBy, filterStudent, filterAssignment)
-
-			// If diff mode is enabled, prompt for case selection
-			if showDiff && len(pairs) > 0 {
-				promptAndShowDiff(pairs, threshold, orderBy)
-			}
+			checkClassroom(opts)
 		},
 	}
 
@@ -169,12 +134,207 @@ func NewCmdCheck(f *cmdutil.Factory) *cobra.Command {
 	cmd.Flags().BoolVarP(&showDiff, "diff", "d", false, "Interactive mode to show diffs for selected cases")
 	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
 	cmd.Flags().StringVarP(&orderBy, "order-by", "o", orderByAssignment, "Order results by 'assignment' or 'student' (default: assignment)")
-	cmd.Flags().StringVarP(&filterStudent, "student", "u", "", "Filter to show only pairs involving this student")
+	cmd.Flags().StringVarP(&filterStudent, "student", "u", "", "Filter to show only pairs involving this student (folder name, GitHub user, email or name)")
 	cmd.Flags().StringVarP(&filterAssignment, "assignment", "n", "", "Filter to show only pairs involving this assignment")
+	cmd.Flags().BoolVar(&includeInactive, "include-inactive", false, "Include folders of dropped and auditing students")
+	cmd.Flags().BoolVar(&allClassrooms, "all-classrooms", false, "Check all classrooms of the workspace")
 
 	return cmd
 }
 
+// checkOptions are the flags of the check command
+type checkOptions struct {
+	fileExtensions   []string
+	threshold        float64
+	starterFolder    string
+	ignoreFiles      []string
+	showDiff         bool
+	verbose          bool
+	orderBy          string
+	filterStudent    string
+	filterAssignment string
+	includeInactive  bool
+	allClassrooms    bool
+}
+
+// checkResult summarizes the check of a classroom or assignment
+type checkResult struct {
+	Classroom  string
+	Assignment string
+	Students   int
+	Flagged    int
+	Skipped    int
+}
+
+// checkClassroom checks the submissions of the assignment or classroom the current
+// directory belongs to
+func checkClassroom(opts checkOptions) checkResult {
+	fileExtensions := opts.fileExtensions
+	threshold := opts.threshold
+	starterFolder := opts.starterFolder
+	ignoreFiles := opts.ignoreFiles
+	showDiff := opts.showDiff
+	verbose := opts.verbose
+	orderBy := opts.orderBy
+	filterStudent := opts.filterStudent
+	filterAssignment := opts.filterAssignment
+	includeInactive := opts.includeInactive
+
+	c, err := mmc.LoadClassroom()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	// Try to find assignment folder first (module-html-css level with students)
+	// If not found, try classroom folder
+	var searchPath string
+	var assignmentName string
+	// Folders of the same team are treated as one submitter and not compared
+	sameSubmitter := func(folder1, folder2 string) bool { return false }
+	assignmentFolder, err := mmc.FindAssignmentFolder()
+	if err == nil {
+		a, err := mmc.LoadAssignment()
+		if err != nil {
+			mmc.Fatal(err)
+		}
+		sameSubmitter = a.ShareMembers
+		assignmentName = filepath.Base(assignmentFolder)
+
+		// We're in or below an assignment folder - use it as the search path
+		searchPath = assignmentFolder
+		err = os.Chdir(assignmentFolder)
+		if err != nil {
+			mmc.Fatal(fmt.Errorf("failed to change to assignment directory: %v", err))
+		}
+	} else {
+		// Not in assignment, try classroom folder
+		classroomFolder, err := mmc.FindClassroomFolder()
+		if err != nil {
+			mmc.Fatal("No classroom or assignment found. Run `gh mmc init` to initialize a classroom folder or change to a classroom/assignment folder.")
+		}
+		searchPath = classroomFolder
+		err = os.Chdir(classroomFolder)
+		if err != nil {
+			mmc.Fatal(fmt.Errorf("failed to change to classroom directory: %v", err))
+		}
+	}
+
+	// Resolve a student given by GitHub user, email or name to the student folder
+	if filterStudent != "" {
+		if s, err := c.FindStudent(filterStudent); err == nil {
+			if name, err := c.StudentFolder(*s); err == nil {
+				filterStudent = name
+			}
+		}
+	}
+
+	// Determine starter folder name from classroom
+	if starterFolder == "" {
+		starterFolder = c.Classroom.Name
+	}
+
+	if verbose {
+		fmt.Printf("Checking classroom: %s\n", c.Classroom.Name)
+		fmt.Printf("Search path: %s\n", searchPath)
+		fmt.Printf("File extensions: %v\n", fileExtensions)
+		fmt.Printf("Threshold: %.0f%%\n", threshold)
+		if len(ignoreFiles) > 0 {
+			fmt.Printf("Ignoring files: %v\n", ignoreFiles)
+		}
+		fmt.Println()
+	}
+
+	// Run the comparison
+	result, err := similarity.CompareAssignments(searchPath, fileExtensions, starterFolder, ignoreFiles, verbose)
+	if err != nil {
+		// A single assignment folder must not stop the check of all classrooms
+		if !opts.allClassrooms {
+			mmc.Fatal(fmt.Errorf("failed to compare assignments: %v", err))
+		}
+		fmt.Printf("Skipping assignment %s: %v\n", assignmentName, err)
+		return checkResult{Classroom: c.Classroom.Name, Assignment: assignmentName}
+	}
+
+	// Get sorted list of students, skipping inactive students
+	inactiveFolders := c.InactiveFolders()
+	skipped := 0
+	students := make([]string, 0, len(result.Results))
+	for student := range result.Results {
+		if !includeInactive && inactiveFolders[student] {
+			skipped++
+			continue
+		}
+		students = append(students, student)
+	}
+	sort.Strings(students)
+
+	if skipped > 0 {
+		fmt.Printf("Skipped %d folders of inactive students, use --include-inactive to include them.\n\n", skipped)
+	}
+
+	if len(students) == 0 {
+		fmt.Println("No student submissions found.")
+		return checkResult{Classroom: c.Classroom.Name, Assignment: assignmentName, Skipped: skipped}
+	}
+
+	// Sort assignments
+	sort.Strings(result.Assignments)
+
+	// Print overall summary and get pairs
+	pairs := printOverallSummary(students, result, threshold, fileExtensions, ignoreFiles, c.Classroom.Name, orderBy, filterStudent, filterAssignment, sameSubmitter)
+
+	// If diff mode is enabled, prompt for case selection
+	if showDiff && len(pairs) > 0 {
+		promptAndShowDiff(pairs, threshold, orderBy)
+	}
+
+	return checkResult{
+		Classroom:  c.Classroom.Name,
+		Assignment: assignmentName,
+		Students:   len(students),
+		Flagged:    len(pairs),
+		Skipped:    skipped,
+	}
+}
+
+// checkAllClassrooms checks the assignment folders of all classrooms of the workspace
+func checkAllClassrooms(opts checkOptions) {
+	classroomFolders, err := mmc.WorkspaceClassroomFolders()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	var results []checkResult
+	for _, classroomFolder := range classroomFolders {
+		assignmentFolders, err := mmc.ListAssignmentFolders(classroomFolder)
+		if err != nil {
+			mmc.Fatal(err)
+		}
+		if len(assignmentFolders) == 0 {
+			fmt.Printf("\nNo assignment folders found in %s.\n", classroomFolder)
+			continue
+		}
+
+		for _, assignmentFolder := range assignmentFolders {
+			fmt.Printf("\n=== %s ===\n\n", assignmentFolder)
+			if err := os.Chdir(assignmentFolder); err != nil {
+				mmc.Fatal(fmt.Errorf("failed to change to assignment directory: %v", err))
+			}
+			results = append(results, checkClassroom(opts))
+		}
+	}
+
+	var total checkResult
+	fmt.Printf("\n%-30s %-30s %8s %8s %8s\n", "CLASSROOM", "ASSIGNMENT", "STUDENTS", "FLAGGED", "SKIPPED")
+	for _, r := range results {
+		fmt.Printf("%-30s %-30s %8d %8d %8d\n", r.Classroom, r.Assignment, r.Students, r.Flagged, r.Skipped)
+		total.Students += r.Students
+		total.Flagged += r.Flagged
+		total.Skipped += r.Skipped
+	}
+	fmt.Printf("%-30s %-30s %8d %8d %8d\n", "TOTAL", fmt.Sprintf("%d assignments", len(results)), total.Students, total.Flagged, total.Skipped)
+}
+
 // FileComparisonDetail stores file comparison details for display
 type FileComparisonDetail struct {
 	File1      string
@@ -198,7 +358,7 @@ type StudentPair struct {
 }
 
 // printOverallSummary prints a summary across all assignments and returns the pairs
-func printOverallSummary(students []string, result *similarity.ComparisonResult, threshold float64, fileExtensions []string, ignoreFiles []string, classroomName string, orderBy string, filterStudent string, filterAssignment string) []StudentPair {
+func printOverallSummary(students []string, result *similarity.ComparisonResult, threshold float64, fileExtensions []string, ignoreFiles []string, classroomName string, orderBy string, filterStudent string, filterAssignment string, sameSubmitter func(string, string) bool) []StudentPair {
 
 	// Print header with parameters
 	fmt.Printf("Checking classroom: %s\n", classroomName)
@@ -227,6 +387,11 @@ func printOverallSummary(students []string, result *similarity.ComparisonResult,
 			student2 := students[j]
 			key := student1 + "|" + student2
 
+			// Skip folders of teams sharing members
+			if sameSubmitter(student1, student2) {
+				continue
+			}
+
 			pair := &StudentPair{
 				Student1:           student1,
 				Student2:           student2,
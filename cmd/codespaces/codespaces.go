@@ -3,6 +3,7 @@ package codespaces
 import (
 	"errors"
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 	"time"
@@ -29,7 +30,8 @@ func NewCmdCodespaces(f *cmdutil.Factory) *cobra.Command {
 
 			The organization is looked up from the classroom metadata if it exists, 
 			otherwise you will be prompted to select an organization from your available 
-			organizations.`),
+			organizations, or need to pass --org with --no-prompt or if stdin is not a 
+			terminal.`),
 	}
 
 	cmd.AddCommand(NewCmdCodespacesList(f))
@@ -41,6 +43,8 @@ func NewCmdCodespaces(f *cmdutil.Factory) *cobra.Command {
 func NewCmdCodespacesList(f *cmdutil.Factory) *cobra.Command {
 	var orgName string
 	var verbose bool
+	var includeInactive bool
+	var allClassrooms bool
 
 	cmd := &cobra.Command{
 		Use:   "list",
@@ -57,24 +61,50 @@ func NewCmdCodespacesList(f *cmdutil.Factory) *cobra.Command {
 
 			The organization is looked up from the classroom metadata if it exists, 
 			otherwise you will be prompted to select an organization from your available 
-			organizations.
+			organizations, or need to pass --org with --no-prompt or if stdin is not a 
+			terminal.
 
 			For each codespace, the command shows detailed information including machine 
-			specifications, prebuild status, and last usage time.`),
+			specifications, prebuild status, and last usage time.
+
+			Codespaces of dropped and auditing students are skipped, unless 
+			--include-inactive is set.
+
+			With --all-classrooms, the codespaces of each classroom below the workspace 
+			folder are listed, followed by a combined summary.`),
 		Example: `$ gh mmc codespaces list
-$ gh mmc codespaces list --org my-org`,
+$ gh mmc codespaces list --org my-org
+$ gh mmc codespaces list --all-classrooms`,
 		Run: func(cmd *cobra.Command, args []string) {
 			client, err := api.DefaultRESTClient()
 			if err != nil {
 				mmc.Fatal(fmt.Errorf("failed to create gh client: %v", err))
 			}
 
+			if allClassrooms {
+				if orgName != "" {
+					mmc.Fatal("--org cannot be used with --all-classrooms")
+				}
+				startingDir, err := os.Getwd()
+				if err != nil {
+					mmc.Fatal(fmt.Errorf("failed to get current directory: %v", err))
+				}
+				defer func() {
+					_ = os.Chdir(startingDir)
+				}()
+				listAllClassroomCodespaces(client, includeInactive)
+				return
+			}
+
 			// Try to get organization from classroom metadata
 			if orgName == "" {
 				c, err := mmc.LoadClassroom()
 				if err != nil {
 					if errors.Is(err, mmc.ErrClassroomNotFound) {
 						// Prompt for organization selection
+						if !f.IOStreams.CanPrompt() {
+							mmc.Fatal(mmc.NoPromptError("--org"))
+						}
 						org, err := ghapi.PromptForOrganization(client)
 						if err != nil {
 							mmc.Fatal(fmt.Errorf("failed to select organization: %v", err))
@@ -131,33 +161,7 @@ $ gh mmc codespaces list --org my-org`,
 				if err == nil {
 					fmt.Printf(" (filtered by classroom: %s)\n", c.Classroom.Name)
 
-					// Get all assignments for this classroom
-					allAssignments, err := ghapi.ListAllAssignments(client, c.Classroom.Id)
-					if err != nil {
-						mmc.Fatal(fmt.Errorf("failed to get classroom assignments: %v", err))
-					}
-
-					// Collect all repository full names from all assignments
-					classroomRepos := make(map[string]bool)
-
-					// For each assignment, get all accepted assignments and their repositories
-					for _, assignment := range allAssignments {
-						acceptedAssignmentList, err := ghapi.ListAllAcceptedAssignments(client, assignment.Id, 15)
-						if err != nil {
-							// Log error but continue with other assignments
-							fmt.Printf("Warning: failed to get accepted assignments for assignment %s: %v\n", assignment.Title, err)
-							continue
-						}
-
-						for _, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
-							classroomRepos[acceptedAssignment.Repository.FullName] = true
-						}
-
-						// Also include the starter code repository if it exists
-						if assignment.StarterCodeRepository.Id != 0 {
-							classroomRepos[assignment.StarterCodeRepository.FullName] = true
-						}
-					}
+					classroomRepos := classroomRepositories(client, c.Classroom.Id)
 
 					// Filter codespaces to only include those from classroom repositories
 					var filteredCodespaces []ghapi.GitHubCodespace
@@ -173,157 +177,305 @@ $ gh mmc codespaces list --org my-org`,
 			}
 			fmt.Println()
 
+			// Skip the codespaces of dropped and auditing students
+			skipped := 0
+			if c, err := mmc.LoadClassroom(); err == nil && !includeInactive {
+				var activeCodespaces []ghapi.GitHubCodespace
+				for _, cs := range codespaces {
+					if s, err := c.FindGithubStudent(cs.Owner.ID, cs.Owner.Login); err == nil && !s.IsActive() {
+						skipped++
+						continue
+					}
+					activeCodespaces = append(activeCodespaces, cs)
+				}
+				codespaces = activeCodespaces
+			}
+
 			if len(codespaces) == 0 {
 				fmt.Printf("No codespaces found for organization %s\n", orgName)
+				if skipped > 0 {
+					fmt.Printf("Skipped %d codespaces of inactive students, use --include-inactive to include them.\n", skipped)
+				}
 				return
 			}
 
-			// Print header with fixed-width formatting to handle emoji alignment
-			fmt.Printf("%-25s %-6s %-35s %-25s %-42s %-8s %-5s %s\n",
-				"NAME", "GIT", "REPOSITORY", "USER", "MACHINE", "IDLE", "PRE", "LAST USED")
-
 			// Load classroom context once for student name lookups
-			classroom, classroomErr := mmc.LoadClassroom()
+			var folderName func(githubId int, githubUser string) (string, error)
+			if classroom, err := mmc.LoadClassroom(); err == nil {
+				folderName = classroom.GetFolderName
+			}
+			printCodespaces(codespaces, orgName, folderName)
 
-			// Create a slice to hold codespace data with student names for sorting
-			type codespaceWithStudent struct {
-				codespace   ghapi.GitHubCodespace
-				studentName string
+			fmt.Printf("\nTotal codespaces: %d\n", len(codespaces))
+			if skipped > 0 {
+				fmt.Printf("Skipped %d codespaces of inactive students, use --include-inactive to include them.\n", skipped)
 			}
+		},
+	}
 
-			var codespacesList []codespaceWithStudent
+	cmd.Flags().StringVarP(&orgName, "org", "o", "", "Organization name (if not provided, will be detected from classroom metadata or prompted)")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose error output")
+	cmd.Flags().BoolVar(&includeInactive, "include-inactive", false, "include codespaces of dropped and auditing students")
+	cmd.Flags().BoolVar(&allClassrooms, "all-classrooms", false, "list the codespaces of all classrooms of the workspace")
 
-			// Populate the list with student names
-			for _, cs := range codespaces {
-				var studentName string
-				if classroomErr == nil {
-					if name, err := classroom.GetRepoName(cs.Owner.Login); err == nil {
-						studentName = name
-					}
-				}
-				codespacesList = append(codespacesList, codespaceWithStudent{
-					codespace:   cs,
-					studentName: studentName,
-				})
-			}
+	return cmd
+}
 
-			// Sort by student name (empty names go to the end)
-			sort.Slice(codespacesList, func(i, j int) bool {
-				// If one student name is empty and the other isn't, put empty ones at the end
-				if codespacesList[i].studentName == "" && codespacesList[j].studentName != "" {
-					return false
-				}
-				if codespacesList[i].studentName != "" && codespacesList[j].studentName == "" {
-					return true
-				}
-				// If both student names are empty, sort by owner (GitHub username)
-				if codespacesList[i].studentName == "" && codespacesList[j].studentName == "" {
-					return codespacesList[i].codespace.Owner.Login < codespacesList[j].codespace.Owner.Login
-				}
-				// Both are non-empty, sort alphabetically by student name
-				return codespacesList[i].studentName < codespacesList[j].studentName
-			})
+// classroomRepositories returns the full names of the student and starter code
+// repositories of all assignments of a classroom
+func classroomRepositories(client *api.RESTClient, classroomId int) map[string]bool {
+	// Get all assignments for this classroom
+	allAssignments, err := ghapi.ListAllAssignments(client, classroomId)
+	if err != nil {
+		mmc.Fatal(fmt.Errorf("failed to get classroom assignments: %v", err))
+	}
 
-			for _, item := range codespacesList {
-				cs := item.codespace
-				studentName := item.studentName
+	// Collect all repository full names from all assignments
+	classroomRepos := make(map[string]bool)
 
-				// Use student name if available, otherwise use GitHub username
-				var displayUser string
-				if studentName != "" {
-					displayUser = studentName
-				} else {
-					displayUser = cs.Owner.Login
-				}
+	// For each assignment, get all accepted assignments and their repositories
+	for _, assignment := range allAssignments {
+		acceptedAssignmentList, err := ghapi.ListAllAcceptedAssignments(client, assignment.Id, 15)
+		if err != nil {
+			// Log error but continue with other assignments
+			fmt.Printf("Warning: failed to get accepted assignments for assignment %s: %v\n", assignment.Title, err)
+			continue
+		}
 
-				// Truncate user name if too long
-				if len(displayUser) > 24 {
-					displayUser = displayUser[:21] + "..."
-				}
+		for _, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
+			classroomRepos[acceptedAssignment.Repository.FullName] = true
+		}
 
-				// Format machine information with consistent padding
-				memoryGB := cs.Machine.MemoryInBytes / (1024 * 1024 * 1024)
-				storageGB := cs.Machine.StorageInBytes / (1024 * 1024 * 1024)
-				machineInfo := fmt.Sprintf("%2d cores, %2d GB RAM, %2d GB storage (%s)",
-					cs.Machine.CPUs, memoryGB, storageGB, cs.Machine.OperatingSystem)
+		// Also include the starter code repository if it exists
+		if assignment.StarterCodeRepository.Id != 0 {
+			classroomRepos[assignment.StarterCodeRepository.FullName] = true
+		}
+	}
 
-				// Handle nullable PrebuildAvailability
-				var availability string
-				if cs.Machine.PrebuildAvailability != nil {
-					availability = *cs.Machine.PrebuildAvailability
-				}
-				prebuildInfo := formatPrebuild(cs.Prebuild, availability)
+	return classroomRepos
+}
 
-				lastUsed := "Never"
-				if cs.LastUsedAt != nil && *cs.LastUsedAt != "" {
-					if t, err := time.Parse(time.RFC3339, *cs.LastUsedAt); err == nil {
-						lastUsed = t.Format("Mon 2006-01-02 15:04")
-					}
-				}
+// printCodespaces prints a table of codespaces sorted by the folder names of their
+// owners as given by folderName, which may be nil if no classroom is known
+func printCodespaces(codespaces []ghapi.GitHubCodespace, orgName string, folderName func(githubId int, githubUser string) (string, error)) {
+	// Print header with fixed-width formatting to handle emoji alignment
+	fmt.Printf("%-25s %-6s %-35s %-25s %-42s %-8s %-5s %s\n",
+		"NAME", "GIT", "REPOSITORY", "USER", "MACHINE", "IDLE", "PRE", "LAST USED")
+
+	// Create a slice to hold codespace data with student names for sorting
+	type codespaceWithStudent struct {
+		codespace   ghapi.GitHubCodespace
+		studentName string
+	}
 
-				// Truncate long names and repositories for better formatting
-				displayName := cs.DisplayName
-				if len(displayName) > 24 {
-					displayName = displayName[:21] + "..."
-				}
+	var codespacesList []codespaceWithStudent
 
-				// Strip organization prefix from repository name since all repos belong to the same org
-				repoName := cs.Repository.FullName
-				if orgPrefix := orgName + "/"; strings.HasPrefix(repoName, orgPrefix) {
-					repoName = repoName[len(orgPrefix):]
-				}
-				if len(repoName) > 34 {
-					repoName = repoName[:31] + "..."
-				}
+	// Populate the list with student names
+	for _, cs := range codespaces {
+		var studentName string
+		if folderName != nil {
+			if name, err := folderName(cs.Owner.ID, cs.Owner.Login); err == nil {
+				studentName = name
+			}
+		}
+		codespacesList = append(codespacesList, codespaceWithStudent{
+			codespace:   cs,
+			studentName: studentName,
+		})
+	}
 
-				// Format idle timeout
-				idleTimeout := fmt.Sprintf("%dm", cs.IdleTimeoutMinutes)
-
-				// Format git status
-				gitStatus := formatGitStatus(cs.GitStatus)
-
-				// Add color coding based on state
-				var colorStart, colorEnd string
-				switch cs.State {
-				case "Available":
-					colorStart = "\033[32m" // Green
-					colorEnd = "\033[0m"    // Reset
-				case "Shutdown":
-					colorStart = "" // Default terminal color
-					colorEnd = ""
-				default:
-					colorStart = "\033[33m" // Yellow
-					colorEnd = "\033[0m"    // Reset
-				}
+	// Sort by student name (empty names go to the end)
+	sort.Slice(codespacesList, func(i, j int) bool {
+		// If one student name is empty and the other isn't, put empty ones at the end
+		if codespacesList[i].studentName == "" && codespacesList[j].studentName != "" {
+			return false
+		}
+		if codespacesList[i].studentName != "" && codespacesList[j].studentName == "" {
+			return true
+		}
+		// If both student names are empty, sort by owner (GitHub username)
+		if codespacesList[i].studentName == "" && codespacesList[j].studentName == "" {
+			return codespacesList[i].codespace.Owner.Login < codespacesList[j].codespace.Owner.Login
+		}
+		// Both are non-empty, sort alphabetically by student name
+		return codespacesList[i].studentName < codespacesList[j].studentName
+	})
 
-				fmt.Printf("%s%-25s %-6s %-35s %-25s %-42s %-8s %-5s %s%s\n",
-					colorStart,
-					displayName,
-					gitStatus,
-					repoName,
-					displayUser,
-					machineInfo,
-					idleTimeout,
-					prebuildInfo,
-					lastUsed,
-					colorEnd,
-				)
+	for _, item := range codespacesList {
+		cs := item.codespace
+		studentName := item.studentName
+
+		// Use student name if available, otherwise use GitHub username
+		var displayUser string
+		if studentName != "" {
+			displayUser = studentName
+		} else {
+			displayUser = cs.Owner.Login
+		}
+
+		// Truncate user name if too long
+		if len(displayUser) > 24 {
+			displayUser = displayUser[:21] + "..."
+		}
+
+		// Format machine information with consistent padding
+		memoryGB := cs.Machine.MemoryInBytes / (1024 * 1024 * 1024)
+		storageGB := cs.Machine.StorageInBytes / (1024 * 1024 * 1024)
+		machineInfo := fmt.Sprintf("%2d cores, %2d GB RAM, %2d GB storage (%s)",
+			cs.Machine.CPUs, memoryGB, storageGB, cs.Machine.OperatingSystem)
+
+		// Handle nullable PrebuildAvailability
+		var availability string
+		if cs.Machine.PrebuildAvailability != nil {
+			availability = *cs.Machine.PrebuildAvailability
+		}
+		prebuildInfo := formatPrebuild(cs.Prebuild, availability)
+
+		lastUsed := "Never"
+		if cs.LastUsedAt != nil && *cs.LastUsedAt != "" {
+			if t, err := time.Parse(time.RFC3339, *cs.LastUsedAt); err == nil {
+				lastUsed = t.Format("Mon 2006-01-02 15:04")
 			}
+		}
 
-			fmt.Printf("\nTotal codespaces: %d\n", len(codespaces))
-		},
+		// Truncate long names and repositories for better formatting
+		displayName := cs.DisplayName
+		if len(displayName) > 24 {
+			displayName = displayName[:21] + "..."
+		}
+
+		// Strip organization prefix from repository name since all repos belong to the same org
+		repoName := cs.Repository.FullName
+		if orgPrefix := orgName + "/"; strings.HasPrefix(repoName, orgPrefix) {
+			repoName = repoName[len(orgPrefix):]
+		}
+		if len(repoName) > 34 {
+			repoName = repoName[:31] + "..."
+		}
+
+		// Format idle timeout
+		idleTimeout := fmt.Sprintf("%dm", cs.IdleTimeoutMinutes)
+
+		// Format git status
+		gitStatus := formatGitStatus(cs.GitStatus)
+
+		// Add color coding based on state
+		var colorStart, colorEnd string
+		switch cs.State {
+		case "Available":
+			colorStart = "\033[32m" // Green
+			colorEnd = "\033[0m"    // Reset
+		case "Shutdown":
+			colorStart = "" // Default terminal color
+			colorEnd = ""
+		default:
+			colorStart = "\033[33m" // Yellow
+			colorEnd = "\033[0m"    // Reset
+		}
+
+		fmt.Printf("%s%-25s %-6s %-35s %-25s %-42s %-8s %-5s %s%s\n",
+			colorStart,
+			displayName,
+			gitStatus,
+			repoName,
+			displayUser,
+			machineInfo,
+			idleTimeout,
+			prebuildInfo,
+			lastUsed,
+			colorEnd,
+		)
 	}
+}
 
-	cmd.Flags().StringVarP(&orgName, "org", "o", "", "Organization name (if not provided, will be detected from classroom metadata or prompted)")
-	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose error output")
+// codespacesResult summarizes the codespaces of a classroom
+type codespacesResult struct {
+	Classroom    string
+	Organization string
+	Codespaces   int
+	Available    int
+	Skipped      int
+}
 
-	return cmd
+// listAllClassroomCodespaces lists the codespaces of each classroom of the workspace,
+// followed by a combined summary
+func listAllClassroomCodespaces(client *api.RESTClient, includeInactive bool) {
+	classroomFolders, err := mmc.WorkspaceClassroomFolders()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	// Classrooms of the same organization share the codespaces fetched once
+	orgCodespaces := make(map[string][]ghapi.GitHubCodespace)
+
+	var results []codespacesResult
+	for _, classroomFolder := range classroomFolders {
+		if err := os.Chdir(classroomFolder); err != nil {
+			mmc.Fatal(fmt.Errorf("failed to change to classroom directory: %v", err))
+		}
+		c, err := mmc.LoadClassroom()
+		if err != nil {
+			mmc.Fatal(err)
+		}
+		orgName := c.Organization.Login
+
+		fmt.Printf("\n=== %s (classroom: %s) ===\n\n", classroomFolder, c.Classroom.Name)
+
+		all, ok := orgCodespaces[orgName]
+		if !ok {
+			all, err = ghapi.GetCodespacesForOrg(client, orgName)
+			if err != nil {
+				mmc.Fatal(fmt.Errorf("failed to get codespaces: %v", err))
+			}
+			orgCodespaces[orgName] = all
+		}
+
+		classroomRepos := classroomRepositories(client, c.Classroom.Id)
+		result := codespacesResult{Classroom: c.Classroom.Name, Organization: orgName}
+		var codespaces []ghapi.GitHubCodespace
+		for _, cs := range all {
+			if !classroomRepos[cs.Repository.FullName] {
+				continue
+			}
+			// Skip the codespaces of dropped and auditing students
+			if s, err := c.FindGithubStudent(cs.Owner.ID, cs.Owner.Login); err == nil && !s.IsActive() && !includeInactive {
+				result.Skipped++
+				continue
+			}
+			if cs.State == "Available" {
+				result.Available++
+			}
+			codespaces = append(codespaces, cs)
+		}
+		result.Codespaces = len(codespaces)
+
+		if len(codespaces) == 0 {
+			fmt.Printf("No codespaces found for classroom %s\n", c.Classroom.Name)
+		} else {
+			printCodespaces(codespaces, orgName, c.GetFolderName)
+		}
+		results = append(results, result)
+	}
+
+	var total codespacesResult
+	fmt.Printf("\n%-30s %-25s %10s %10s %8s\n", "CLASSROOM", "ORGANIZATION", "CODESPACES", "AVAILABLE", "SKIPPED")
+	for _, r := range results {
+		fmt.Printf("%-30s %-25s %10d %10d %8d\n", r.Classroom, r.Organization, r.Codespaces, r.Available, r.Skipped)
+		total.Codespaces += r.Codespaces
+		total.Available += r.Available
+		total.Skipped += r.Skipped
+	}
+	fmt.Printf("%-30s %-25s %10d %10d %8d\n", "TOTAL", "", total.Codespaces, total.Available, total.Skipped)
+	if total.Skipped > 0 {
+		fmt.Printf("Skipped %d codespaces of inactive students, use --include-inactive to include them.\n", total.Skipped)
+	}
 }
 
 func NewCmdCodespacesRm(f *cmdutil.Factory) *cobra.Command {
 	var orgName string
 	var verbose bool
 	var all bool
+	var yes bool
 
 	cmd := &cobra.Command{
 		Use:   "rm",
@@ -342,15 +494,18 @@ func NewCmdCodespacesRm(f *cmdutil.Factory) *cobra.Command {
 
 			Use the --all flag to automatically delete all non-running codespaces 
 			without interactive selection. For safety, --all only deletes codespaces 
-			with clean git status (no uncommitted or unpushed changes).
+			with clean git status (no uncommitted or unpushed changes). The deletion 
+			needs to be confirmed, unless --yes is set.
 
 			The organization is looked up from the classroom metadata if it exists, 
 			otherwise you will be prompted to select an organization from your available 
-			organizations.`),
+			organizations, or need to pass --org with --no-prompt or if stdin is not a 
+			terminal.`),
 		Example: `$ gh mmc codespaces rm
 $ gh mmc codespaces rm --org my-org
 $ gh mmc codespaces rm --all
-$ gh mmc codespaces rm --org my-org --all`,
+$ gh mmc codespaces rm --org my-org --all
+$ gh mmc codespaces rm --all --yes --no-prompt`,
 		Run: func(cmd *cobra.Command, args []string) {
 			client, err := api.DefaultRESTClient()
 			if err != nil {
@@ -363,6 +518,9 @@ $ gh mmc codespaces rm --org my-org --all`,
 				if err != nil {
 					if errors.Is(err, mmc.ErrClassroomNotFound) {
 						// Prompt for organization selection
+						if !f.IOStreams.CanPrompt() {
+							mmc.Fatal(mmc.NoPromptError("--org"))
+						}
 						org, err := ghapi.PromptForOrganization(client)
 						if err != nil {
 							mmc.Fatal(fmt.Errorf("failed to select organization: %v", err))
@@ -419,33 +577,7 @@ $ gh mmc codespaces rm --org my-org --all`,
 				if err == nil {
 					fmt.Printf(" (filtered by classroom: %s)\n", c.Classroom.Name)
 
-					// Get all assignments for this classroom
-					allAssignments, err := ghapi.ListAllAssignments(client, c.Classroom.Id)
-					if err != nil {
-						mmc.Fatal(fmt.Errorf("failed to get classroom assignments: %v", err))
-					}
-
-					// Collect all repository full names from all assignments
-					classroomRepos := make(map[string]bool)
-
-					// For each assignment, get all accepted assignments and their repositories
-					for _, assignment := range allAssignments {
-						acceptedAssignmentList, err := ghapi.ListAllAcceptedAssignments(client, assignment.Id, 15)
-						if err != nil {
-							// Log error but continue with other assignments
-							fmt.Printf("Warning: failed to get accepted assignments for assignment %s: %v\n", assignment.Title, err)
-							continue
-						}
-
-						for _, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
-							classroomRepos[acceptedAssignment.Repository.FullName] = true
-						}
-
-						// Also include the starter code repository if it exists
-						if assignment.StarterCodeRepository.Id != 0 {
-							classroomRepos[assignment.StarterCodeRepository.FullName] = true
-						}
-					}
+					classroomRepos := classroomRepositories(client, c.Classroom.Id)
 
 					// Filter codespaces to only include those from classroom repositories
 					var filteredCodespaces []ghapi.GitHubCodespace
@@ -481,7 +613,7 @@ $ gh mmc codespaces rm --org my-org --all`,
 			for _, cs := range codespaces {
 				var studentName string
 				if classroomErr == nil {
-					if name, err := classroom.GetRepoName(cs.Owner.Login); err == nil {
+					if name, err := classroom.GetFolderName(cs.Owner.ID, cs.Owner.Login); err == nil {
 						studentName = name
 					}
 				}
@@ -547,6 +679,9 @@ $ gh mmc codespaces rm --org my-org --all`,
 				displayCodespacesTable(selectedCodespaces, orgName)
 			} else {
 				// Prompt user to select codespaces to delete
+				if !f.IOStreams.CanPrompt() {
+					mmc.Fatal(mmc.NoPromptError("--all"))
+				}
 				var err error
 
 				// Create getUserDisplayName callback function
@@ -571,7 +706,7 @@ $ gh mmc codespaces rm --org my-org --all`,
 			}
 
 			// Delete selected codespaces
-			err = deleteSelectedCodespaces(client, orgName, selectedCodespaces, verbose)
+			err = deleteSelectedCodespaces(client, orgName, selectedCodespaces, yes, f.IOStreams.CanPrompt(), verbose)
 			if err != nil {
 				mmc.Fatal(fmt.Errorf("failed to delete selected codespaces: %v", err))
 			}
@@ -581,6 +716,7 @@ $ gh mmc codespaces rm --org my-org --all`,
 	cmd.Flags().StringVarP(&orgName, "org", "o", "", "Organization name (if not provided, will be detected from classroom metadata or prompted)")
 	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose error output")
 	cmd.Flags().BoolVarP(&all, "all", "a", false, "Delete all clean non-running codespaces (excludes those with uncommitted/unpushed changes)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Delete without confirmation")
 
 	return cmd
 }
@@ -638,21 +774,13 @@ func formatGitStatus(gitStatus ghapi.GitHubCodespaceGitStatus) string {
 	return strings.Join(status, ",")
 }
 
-// deleteSelectedCodespaces deletes the specified codespaces
-func deleteSelectedCodespaces(client *api.RESTClient, orgName string, codespaces []ghapi.GitHubCodespace, verbose bool) error {
+// deleteSelectedCodespaces deletes the specified codespaces after confirmation, unless
+// yes is set. It fails asking for --yes if prompts are disabled.
+func deleteSelectedCodespaces(client *api.RESTClient, orgName string, codespaces []ghapi.GitHubCodespace, yes bool, canPrompt bool, verbose bool) error {
 	fmt.Printf("You selected %d codespace(s) for deletion.\n", len(codespaces))
 
 	// Ask for confirmation
-	fmt.Print("\nAre you sure you want to delete these codespaces? (y/N): ")
-	var response string
-	_, err := fmt.Scanln(&response)
-	if err != nil {
-		// Handle input error (e.g., EOF, interrupted input)
-		fmt.Println("\nDeletion cancelled.")
-		return nil
-	}
-
-	if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
+	if !yes && !mmc.Confirm(canPrompt, "\nAre you sure you want to delete these codespaces?") {
 		fmt.Println("Deletion cancelled.")
 		return nil
 	}
@@ -713,7 +841,7 @@ func displayCodespacesTable(codespaces []ghapi.GitHubCodespace, orgName string)
 		// Check user display name length (student name or GitHub username)
 		var displayUser string
 		if classroomErr == nil {
-			if name, err := classroom.GetRepoName(cs.Owner.Login); err == nil {
+			if name, err := classroom.GetFolderName(cs.Owner.ID, cs.Owner.Login); err == nil {
 				displayUser = name
 			}
 		}
@@ -748,7 +876,7 @@ func displayCodespacesTable(codespaces []ghapi.GitHubCodespace, orgName string)
 		// Get user display name (student name if available, otherwise GitHub username)
 		var displayUser string
 		if classroomErr == nil {
-			if name, err := classroom.GetRepoName(cs.Owner.Login); err == nil {
+			if name, err := classroom.GetFolderName(cs.Owner.ID, cs.Owner.Login); err == nil {
 				displayUser = name
 			}
 		}
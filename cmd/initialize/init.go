@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/cli/cli/v2/pkg/cmdutil"
@@ -15,6 +16,11 @@ import (
 
 func NewCmdInit(f *cmdutil.Factory) *cobra.Command {
 	var cId int
+	var roster mmc.RosterConfig
+	var yes bool
+	var classroomRoster string
+	var folderTemplate string
+	var classroomName string
 
 	cmd := &cobra.Command{
 		Use:   "init",
@@ -23,17 +29,75 @@ func NewCmdInit(f *cmdutil.Factory) *cobra.Command {
 		
 			Initializes the local repository for GitHub Classroom using a list of accounts.
 
-			The accounts are read from an Excel file in the current directory that matches 
-			the filename pattern [Aa]ccounts*.xlsx. It must contain a header in the first 
-			row with following fields:
+			The accounts are read from a roster file in the current directory that matches 
+			the filename pattern [Aa]ccounts*. Supported formats are Excel (.xlsx), 
+			OpenDocument (.ods), comma or semicolon separated (.csv) and tab separated 
+			(.tsv) files. It must contain a header in the first row with following fields:
 
 			- Name         ... Full name of the student
 			- Email        ... Email address of the student
 			- GitHub User  ... GitHub username of the student
 
-			If the classroom-id is known, it can be passed as an argument. Otherwise, the 
-			user will be prompted to select a classroom.`),
-		Example: `$ gh mmc init`,
+			Common alternative headers, e.g., "E-Mail" or "GitHub-Benutzername", are 
+			recognized automatically. Other headers can be mapped with the --name-column, 
+			--email-column and --github-user-column flags. Spreadsheets are read from the 
+			sheet "Sheet1" or the first sheet, another sheet can be selected with --sheet. 
+			The roster settings are stored in the classroom metadata and reused when 
+			the classroom is initialized again.
+
+			With --from-classroom-roster, the students are imported from the roster CSV 
+			file exported from GitHub Classroom, with the columns identifier, 
+			github_username, github_id and name. If an accounts file is present as well, 
+			both are merged by matching the identifier with the email or name of the 
+			accounts, or by GitHub username. Students found in only one of the files are 
+			reported and included as well.
+
+			Student folders are named by the folder template, which defaults to 
+			{{.LastName}}.{{.FirstName}}. The template can use the fields .Name, 
+			.FirstName, .LastName, .Email, .EmailLocal and .GithubUser and the 
+			functions slug, lower and upper, e.g., {{.GithubUser}} or {{.Name | slug}}. 
+			First and last name are taken from emails in the format 
+			firstname.lastname@domain, otherwise from the name. Folder names are 
+			transliterated to ASCII, e.g., ä to ae, é to e and ß to ss, and spaces, 
+			apostrophes and other special characters are removed, while the names 
+			in the classroom metadata are kept as they are. When the template of 
+			an initialized classroom changes, existing student folders in the 
+			assignment folders are renamed after confirmation.
+
+			The folder name of each student is stored in the classroom metadata and 
+			does not change on later runs. If the folder names of several students 
+			collide, they get a suffix with their GitHub user id.
+
+			The GitHub user ids of the students are looked up and stored in the 
+			classroom metadata. Students are matched by their id first, so a renamed 
+			GitHub user keeps mapping to the right student, and the stored GitHub 
+			user is updated by pull and sync. The stored GitHub user of a student 
+			with a known id is kept on later runs, even if the roster lists another 
+			one; change it with gh mmc students edit.
+
+			If the classroom is already initialized, the roster is merged into the 
+			existing classroom metadata. Added, removed and changed students are shown 
+			and need to be confirmed before they are saved. Students that are no longer 
+			in the roster are kept as dropped.
+
+			If the classroom-id is known, it can be passed as an argument, or the 
+			classroom can be selected by name with --classroom. Otherwise, the user will 
+			be prompted to select a classroom. With --no-prompt or if stdin is not a 
+			terminal, the classroom and --yes need to be passed instead.`),
+		Example: heredoc.Doc(`
+			$ gh mmc init
+
+			# Read a Microsoft Forms export with custom headers from the sheet "Antworten"
+			$ gh mmc init --sheet Antworten --github-user-column "Dein GitHub-Name"
+
+			# Import the roster exported from GitHub Classroom
+			$ gh mmc init --from-classroom-roster classroom_roster.csv
+
+			# Name student folders after their GitHub user
+			$ gh mmc init --folder-template "{{.GithubUser}}"
+
+			# Initialize without prompting, e.g., in a script
+			$ gh mmc init --classroom "Web Development 2a" --yes --no-prompt`),
 		Run: func(cmd *cobra.Command, args []string) {
 			// Save the starting directory to return to it at the end
 			startingDir, err := os.Getwd()
@@ -49,32 +113,100 @@ func NewCmdInit(f *cmdutil.Factory) *cobra.Command {
 				mmc.Fatal(fmt.Errorf("failed to create gh client: %v", err))
 			}
 
-			as, err := mmc.ReadAccounts()
+			if folderTemplate != "" {
+				if _, err := mmc.ParseFolderTemplate(folderTemplate); err != nil {
+					mmc.Fatal(err)
+				}
+			}
+
+			// A classroom folder must not be nested in another classroom folder
+			if classroomFolder, err := mmc.FindClassroomFolder(); err == nil && classroomFolder != startingDir {
+				mmc.Fatal(fmt.Errorf("classroom folder exists in the folder hierarchy above, but the current folder is not a classroom folder: Change to the classroom folder"))
+			}
+
+			// Hold the lock while reading and changing the classroom metadata, so
+			// changes by other commands in between are not overwritten
+			lock, err := mmc.LockFolder(startingDir)
 			if err != nil {
-				mmc.Fatal(fmt.Errorf("failed to read accounts: %v", err))
+				mmc.Fatal(err)
 			}
+			defer lock.Unlock() //nolint:errcheck
 
+			var oldFolderTemplate string
+			var oldFolders []string
 			c, err := mmc.LoadClassroom()
 			if err != nil {
-				if errors.Is(err, mmc.ErrClassroomNotFound) {
-					c, err := ghapi.PromptForClassroom(client)
-					if err != nil {
-						mmc.Fatal(fmt.Errorf("failed to get classroom: %v", err))
-					}
-
-					cId = c.Id
-				} else {
+				if !errors.Is(err, mmc.ErrClassroomNotFound) {
 					mmc.Fatal(err)
 				}
 			} else {
-				isClassroomFolder, err := mmc.IsClassroomFolder()
+				cId = c.Classroom.Id
+
+				// reuse the stored roster settings unless overridden by flags
+				roster = roster.WithDefaults(c.Roster)
+				oldFolderTemplate = c.FolderTemplate
+				oldFolders = c.StudentFolders()
+				if folderTemplate == "" {
+					folderTemplate = c.FolderTemplate
+				}
+			}
+
+			as, err := mmc.ReadAccounts(roster)
+			if err != nil && (classroomRoster == "" || !errors.Is(err, mmc.ErrAccountsNotFound)) {
+				mmc.Fatal(fmt.Errorf("failed to read accounts: %v", err))
+			}
+
+			if classroomRoster != "" {
+				entries, err := mmc.ReadClassroomRoster(classroomRoster)
 				if err != nil {
-					mmc.Fatal(err)
-				} else if !isClassroomFolder {
-					mmc.Fatal(fmt.Errorf("classroom folder exists in the folder hierarchy above, but the current folder is not a classroom folder: Change to the classroom folder"))
+					mmc.Fatal(fmt.Errorf("failed to read classroom roster: %v", err))
+				}
+
+				m := mmc.MergeClassroomRoster(entries, as)
+				if len(as) > 0 {
+					printClassroomRosterMerge(m)
+				}
+				as = m.Students
+			}
+
+			// Resolve the immutable GitHub user ids, so students keep their folders
+			// when they rename their GitHub user. Students with a stored id keep it
+			// with their GitHub user, as the roster may list an outdated GitHub user.
+			for i := range as {
+				a := &as[i]
+				if a.GithubId != 0 || !mmc.IsValidGithubLogin(a.GithubUser) {
+					continue
+				}
+				if c != nil {
+					if id, login, ok := c.KnownGithubUser(*a); ok {
+						a.GithubId = id
+						a.GithubUser = login
+						continue
+					}
+				}
+				user, err := ghapi.GetUser(client, a.GithubUser)
+				if err != nil {
+					fmt.Printf("Warning: failed to look up GitHub user %s of %s: %v\n", a.GithubUser, a.Name, err)
+					continue
+				}
+				a.GithubId = user.Id
+				a.GithubUser = user.Login
+			}
+
+			if cId == 0 {
+				var c ghapi.GitHubClassroom
+				if classroomName != "" {
+					c, err = ghapi.FindClassroom(client, classroomName)
+				} else if f.IOStreams.CanPrompt() {
+					c, err = ghapi.PromptForClassroom(client)
 				} else {
-					cId = c.Classroom.Id
+					err = mmc.NoPromptError("--classroom <name> or --classroom-id")
 				}
+				if err != nil {
+					mmc.Fatal(fmt.Errorf("failed to get classroom: %v", err))
+				}
+
+				cId = c.Id
 			}
 
 			cls, err := ghapi.GetClassroom(client, cId)
@@ -82,19 +214,170 @@ func NewCmdInit(f *cmdutil.Factory) *cobra.Command {
 				mmc.Fatal(fmt.Errorf("failed to get classroom: %v", err))
 			}
 
-			c = mmc.NewClassroom()
+			isReinit := c != nil
+			if !isReinit {
+				c = mmc.NewClassroom()
+			}
 			c.SetOrganization(cls.Organization.Id, cls.Organization.Login)
 			c.SetClassroom(cls.Id, cls.Name)
-			for _, a := range as {
-				c.AddStudent(a.Name, a.Email, a.GithubUser)
+			c.SetRoster(roster)
+			if folderTemplate == "" {
+				folderTemplate = mmc.DefaultFolderTemplate
+			}
+			c.SetFolderTemplate(folderTemplate)
+			diff := c.MergeStudents(as)
+
+			if isReinit {
+				if diff.IsEmpty() {
+					fmt.Println("No roster changes.")
+				} else {
+					printRosterDiff(diff)
+					if !yes && !mmc.Confirm(f.IOStreams.CanPrompt(), "\nSave roster changes to the classroom?") {
+						fmt.Println("Initialization cancelled.")
+						return
+					}
+				}
+			} else {
+				fmt.Printf("Initialized classroom %s with %d students.\n", cls.Name, len(diff.Added))
+			}
+
+			// Name the folders of new students, or of all students if the folder template has changed
+			templateChanged := isReinit && oldFolderTemplate != folderTemplate
+			if templateChanged {
+				c.ResetFolders()
+			}
+			_, collisions := c.ResolveFolders()
+			printFolderCollisions(collisions)
+			for _, s := range c.Students {
+				if s.Folder == "" {
+					if _, err := c.FolderName(s); err != nil {
+						fmt.Printf("Warning: %v\n", err)
+					}
+				}
+			}
+
+			// Rename the student folders of all assignments if the folder template has changed
+			var renames []mmc.FolderRename
+			if templateChanged {
+				classroomFolder, err := os.Getwd()
+				if err != nil {
+					mmc.Fatal(fmt.Errorf("failed to get current directory: %v", err))
+				}
+
+				var conflicts []mmc.FolderRename
+				renames, conflicts, err = c.PlanFolderRenames(classroomFolder, oldFolders)
+				if err != nil {
+					mmc.Fatal(fmt.Errorf("failed to plan renaming of student folders: %v", err))
+				}
+
+				for _, r := range conflicts {
+					fmt.Printf("Cannot rename %s: %s already exists\n", relPath(classroomFolder, r.From), filepath.Base(r.To))
+				}
+				if len(renames) > 0 {
+					fmt.Println("\nThe folder template has changed, student folders to rename:")
+					for _, r := range renames {
+						fmt.Printf("  %s -> %s\n", relPath(classroomFolder, r.From), filepath.Base(r.To))
+					}
+					if !yes && !mmc.Confirm(f.IOStreams.CanPrompt(), fmt.Sprintf("\nRename %d student folders?", len(renames))) {
+						fmt.Println("Initialization cancelled.")
+						return
+					}
+				}
+			}
+
+			// Rename the folders before saving, so the classroom never names folders that
+			// do not exist, and rename them back if saving fails
+			if len(renames) > 0 {
+				if err := mmc.ApplyFolderRenames(renames); err != nil {
+					mmc.Fatal(err)
+				}
 			}
+
 			err = c.Save(".")
 			if err != nil {
+				if rollbackErr := mmc.RevertFolderRenames(renames); rollbackErr != nil {
+					mmc.Fatal(fmt.Errorf("failed to save classroom: %v\n%v", err, rollbackErr))
+				}
 				mmc.Fatal(fmt.Errorf("failed to save classroom: %v", err))
 			}
+
+			if len(renames) > 0 {
+				fmt.Printf("Renamed %d student folders.\n", len(renames))
+			}
 		},
 	}
 
 	cmd.Flags().IntVarP(&cId, "classroom-id", "c", 0, "ID of the classroom")
+	cmd.Flags().StringVar(&classroomName, "classroom", "", "name of the classroom, instead of its ID")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "save roster changes without confirmation")
+	cmd.Flags().StringVar(&folderTemplate, "folder-template", "", "template to name student folders (defaults to {{.LastName}}.{{.FirstName}})")
+	cmd.Flags().StringVar(&classroomRoster, "from-classroom-roster", "", "roster CSV file exported from GitHub Classroom to import the students from")
+	cmd.Flags().StringVar(&roster.Sheet, "sheet", "", "name of the sheet containing the accounts (defaults to Sheet1 or the first sheet)")
+	cmd.Flags().StringVar(&roster.NameColumn, "name-column", "", "header of the column containing the full name of the student")
+	cmd.Flags().StringVar(&roster.EmailColumn, "email-column", "", "header of the column containing the email address of the student")
+	cmd.Flags().StringVar(&roster.GithubUserColumn, "github-user-column", "", "header of the column containing the GitHub username of the student")
 	return cmd
 }
+
+// printRosterDiff prints the added, removed, reactivated and changed students
+func printRosterDiff(diff mmc.RosterDiff) {
+	fmt.Println("Roster changes:")
+	for _, s := range diff.Added {
+		fmt.Printf("  + %-30s %-40s %s\n", s.Name, s.Email, s.GithubUser)
+	}
+	for _, s := range diff.Reactivated {
+		fmt.Printf("  * %-30s %-40s %s (reactivated)\n", s.Name, s.Email, s.GithubUser)
+	}
+	for _, s := range diff.Removed {
+		fmt.Printf("  - %-30s %-40s %s (kept as dropped)\n", s.Name, s.Email, s.GithubUser)
+	}
+	for _, ch := range diff.Changed {
+		fmt.Printf("  ~ %s\n", ch.Student.Name)
+		for _, f := range ch.Fields {
+			fmt.Printf("      %-12s %s -> %s\n", f.Field+":", f.Old, f.New)
+		}
+	}
+	fmt.Printf("\n%d added, %d reactivated, %d removed, %d changed.\n",
+		len(diff.Added), len(diff.Reactivated), len(diff.Removed), len(diff.Changed))
+}
+
+// printFolderCollisions prints the students whose folder names collide and the folder
+// names they got instead
+func printFolderCollisions(collisions []mmc.FolderCollision) {
+	for _, col := range collisions {
+		fmt.Printf("Folder name %s is not unique, using instead:\n", col.Folder)
+		for _, s := range col.Students {
+			fmt.Printf("  %-30s %s\n", s.Name, s.Folder)
+		}
+	}
+	if len(collisions) > 0 {
+		fmt.Println()
+	}
+}
+
+// relPath returns path relative to base, or path if that fails
+func relPath(base, path string) string {
+	if rel, err := filepath.Rel(base, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// printClassroomRosterMerge reports the students found only in the classroom roster
+// or only in the accounts file
+func printClassroomRosterMerge(m mmc.ClassroomRosterMerge) {
+	if len(m.UnmatchedEntries) > 0 {
+		fmt.Printf("%d students of the classroom roster not found in the accounts file:\n", len(m.UnmatchedEntries))
+		for _, e := range m.UnmatchedEntries {
+			fmt.Printf("  - %-40s %s\n", e.Identifier, e.GithubUser)
+		}
+		fmt.Println()
+	}
+	if len(m.UnmatchedAccounts) > 0 {
+		fmt.Printf("%d students of the accounts file not found in the classroom roster:\n", len(m.UnmatchedAccounts))
+		for _, a := range m.UnmatchedAccounts {
+			fmt.Printf("  - %-30s %-40s %s\n", a.Name, a.Email, a.GithubUser)
+		}
+		fmt.Println()
+	}
+}
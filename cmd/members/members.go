@@ -0,0 +1,303 @@
+package members
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/cli/cli/v2/pkg/cmdutil"
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/majikmate/gh-mmc/pkg/ghapi"
+	"github.com/majikmate/gh-mmc/pkg/mmc"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdMembers(f *cmdutil.Factory) *cobra.Command {
+	var invite bool
+	var remove bool
+	var yes bool
+	var verbose bool
+
+	cmd := &cobra.Command{
+		Use:   "members",
+		Short: "Compare the students with the members of the organization",
+		Long: heredoc.Doc(`
+
+			Compares the students of the classroom with the members and pending
+			invitations of the organization of the classroom.
+
+			Active and auditing students who are neither members nor invited are
+			reported as missing. With --invite, they are invited to the organization
+			by their GitHub user id, so the invitation follows renamed GitHub users.
+
+			Dropped students who are still members or invited are reported as having
+			left the roster. With --remove, they are removed from the organization
+			after confirmation. They are removed by the current login of their GitHub
+			user id, so a login taken over after a rename is never removed. Owners of
+			the organization, members who are not students of the classroom, e.g.,
+			teachers, and students who are active or auditing in another classroom of
+			the same organization in the workspace are never removed.`),
+		Example: heredoc.Doc(`
+			$ gh mmc members
+
+			# Invite the students missing in the organization
+			$ gh mmc members --invite
+
+			# Remove dropped students from the organization
+			$ gh mmc members --remove`),
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := api.DefaultRESTClient()
+			if err != nil {
+				mmc.Fatal(fmt.Errorf("failed to create gh client: %v", err))
+			}
+
+			c, err := mmc.LoadClassroom()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			orgName := c.Organization.Login
+
+			members, err := ghapi.ListAllOrgMembers(client, orgName, ghapi.OrgRoleAll)
+			if err != nil {
+				mmc.Fatal(fmt.Errorf("failed to get members of organization %s: %v", orgName, err))
+			}
+			owners, err := ghapi.ListAllOrgMembers(client, orgName, ghapi.OrgRoleAdmin)
+			if err != nil {
+				mmc.Fatal(fmt.Errorf("failed to get owners of organization %s: %v", orgName, err))
+			}
+			invitations, err := ghapi.ListAllOrgInvitations(client, orgName)
+			if err != nil {
+				mmc.Fatal(fmt.Errorf("failed to get invitations of organization %s: %v", orgName, err))
+			}
+
+			// The current logins of the members, as the stored logins may be outdated
+			memberIds := map[int]string{}
+			memberLogins := map[string]bool{}
+			for _, m := range members {
+				memberIds[m.Id] = m.Login
+				memberLogins[strings.ToLower(m.Login)] = true
+			}
+			ownerIds := map[int]bool{}
+			ownerLogins := map[string]bool{}
+			for _, o := range owners {
+				ownerIds[o.Id] = true
+				ownerLogins[strings.ToLower(o.Login)] = true
+			}
+			invitedLogins := map[string]bool{}
+			for _, inv := range invitations {
+				if inv.Login != "" {
+					invitedLogins[strings.ToLower(inv.Login)] = true
+				}
+			}
+
+			// A student is matched by GitHub user id if known, otherwise by GitHub user
+			isMember := func(githubId int, githubUser string) bool {
+				if githubId != 0 {
+					return memberIds[githubId] != ""
+				}
+				return memberLogins[strings.ToLower(githubUser)]
+			}
+			isOwner := func(githubId int, githubUser string) bool {
+				return ownerIds[githubId] || ownerLogins[strings.ToLower(githubUser)]
+			}
+
+			// Students of other classrooms of the organization in the workspace, never removed
+			otherIds, otherLogins, err := otherClassroomStudents(orgName, c.Classroom.Id)
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			isOtherStudent := func(githubId int, githubUser string) bool {
+				return (githubId != 0 && otherIds[githubId]) || otherLogins[strings.ToLower(githubUser)]
+			}
+
+			type entry struct {
+				name       string
+				githubUser string
+				githubId   int
+				login      string
+				state      string
+			}
+			var missing, left, ownersLeft, othersLeft []entry
+			membersCount, invitedCount := 0, 0
+			for _, s := range c.Students {
+				e := entry{name: s.Name, githubUser: s.GithubUser, githubId: s.GithubId}
+				member := isMember(s.GithubId, s.GithubUser)
+				invited := invitedLogins[strings.ToLower(s.GithubUser)]
+
+				if s.Status == mmc.StatusDropped {
+					e.login = s.GithubUser
+					if member && s.GithubId != 0 {
+						e.login = memberIds[s.GithubId]
+					}
+					switch {
+					case member && isOwner(s.GithubId, e.login):
+						e.state = "owner"
+						ownersLeft = append(ownersLeft, e)
+					case (member || invited) && isOtherStudent(s.GithubId, e.login):
+						e.state = "other classroom"
+						othersLeft = append(othersLeft, e)
+					case member:
+						e.state = "member"
+						left = append(left, e)
+					case invited:
+						e.state = "invited"
+						left = append(left, e)
+					}
+					continue
+				}
+
+				switch {
+				case member:
+					membersCount++
+				case invited:
+					invitedCount++
+				default:
+					missing = append(missing, e)
+				}
+			}
+
+			fmt.Printf("Organization: %s (classroom: %s)\n\n", orgName, c.Classroom.Name)
+			fmt.Printf("%d students are members, %d students are invited, %d students are missing.\n", membersCount, invitedCount, len(missing))
+
+			if len(missing) > 0 {
+				fmt.Println("\nStudents missing in the organization:")
+				for _, e := range missing {
+					fmt.Printf("  - %-30s %s\n", e.name, e.githubUser)
+				}
+			}
+			if len(left) > 0 {
+				fmt.Println("\nDropped students still in the organization:")
+				for _, e := range left {
+					fmt.Printf("  - %-30s %-25s %s\n", e.name, e.login, e.state)
+				}
+			}
+			if len(ownersLeft) > 0 {
+				fmt.Println("\nDropped students who are owners of the organization (never removed):")
+				for _, e := range ownersLeft {
+					fmt.Printf("  - %-30s %s\n", e.name, e.login)
+				}
+			}
+			if len(othersLeft) > 0 {
+				fmt.Println("\nDropped students who are students of another classroom (never removed):")
+				for _, e := range othersLeft {
+					fmt.Printf("  - %-30s %s\n", e.name, e.login)
+				}
+			}
+
+			if invite && len(missing) > 0 {
+				fmt.Println()
+				invited, failed := 0, 0
+				for _, e := range missing {
+					githubId := e.githubId
+					if githubId == 0 {
+						user, err := ghapi.GetUser(client, e.githubUser)
+						if err != nil {
+							fmt.Printf("Failed to invite: %s (%s): %v\n", e.name, e.githubUser, err)
+							failed++
+							continue
+						}
+						githubId = user.Id
+					}
+
+					if _, err := ghapi.InviteOrgMember(client, orgName, githubId); err != nil {
+						if verbose {
+							fmt.Printf("Failed to invite: %s (%s): %v\n", e.name, e.githubUser, err)
+						} else {
+							fmt.Printf("Failed to invite: %s (%s)\n", e.name, e.githubUser)
+						}
+						failed++
+						continue
+					}
+					fmt.Printf("Invited: %s (%s)\n", e.name, e.githubUser)
+					invited++
+				}
+				fmt.Printf("\nInvited %d out of %d students.\n", invited, invited+failed)
+			} else if len(missing) > 0 {
+				fmt.Println("\nRun with --invite to invite the missing students.")
+			}
+
+			if remove && len(left) > 0 {
+				if !yes && !mmc.Confirm(f.IOStreams.CanPrompt(), fmt.Sprintf("\nRemove %d dropped students from organization %s?", len(left), orgName)) {
+					fmt.Println("Removal cancelled.")
+					return
+				}
+
+				fmt.Println()
+				removed, failed := 0, 0
+				for _, e := range left {
+					if err := ghapi.RemoveOrgMember(client, orgName, e.login); err != nil {
+						if verbose {
+							fmt.Printf("Failed to remove: %s (%s): %v\n", e.name, e.login, err)
+						} else {
+							fmt.Printf("Failed to remove: %s (%s)\n", e.name, e.login)
+						}
+						failed++
+						continue
+					}
+					fmt.Printf("Removed: %s (%s)\n", e.name, e.login)
+					removed++
+				}
+				fmt.Printf("\nRemoved %d out of %d dropped students.\n", removed, removed+failed)
+			} else if len(left) > 0 {
+				fmt.Println("\nRun with --remove to remove the dropped students.")
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&invite, "invite", false, "invite the students missing in the organization")
+	cmd.Flags().BoolVar(&remove, "remove", false, "remove dropped students from the organization after confirmation")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "remove dropped students without confirmation")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose error output")
+
+	return cmd
+}
+
+// otherClassroomStudents returns the GitHub user ids and lowercased logins of the active
+// and auditing students of the other classrooms of the organization in the workspace.
+// Without a workspace, there are no other classrooms to consider.
+func otherClassroomStudents(orgName string, classroomId int) (map[int]bool, map[string]bool, error) {
+	ids := map[int]bool{}
+	logins := map[string]bool{}
+
+	classroomFolders, err := mmc.WorkspaceClassroomFolders()
+	if errors.Is(err, mmc.ErrWorkspaceNotFound) {
+		return ids, logins, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	startingDir, err := os.Getwd()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get current directory: %v", err)
+	}
+	defer func() {
+		_ = os.Chdir(startingDir)
+	}()
+
+	for _, classroomFolder := range classroomFolders {
+		if err := os.Chdir(classroomFolder); err != nil {
+			return nil, nil, fmt.Errorf("failed to change to classroom directory: %v", err)
+		}
+		other, err := mmc.LoadClassroom()
+		if err != nil {
+			return nil, nil, err
+		}
+		if other.Classroom.Id == classroomId || !strings.EqualFold(other.Organization.Login, orgName) {
+			continue
+		}
+		for _, s := range other.Students {
+			if s.Status == mmc.StatusDropped {
+				continue
+			}
+			if s.GithubId != 0 {
+				ids[s.GithubId] = true
+			}
+			logins[strings.ToLower(s.GithubUser)] = true
+		}
+	}
+
+	return ids, logins, nil
+}
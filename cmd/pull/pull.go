@@ -2,11 +2,14 @@ package pull
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/cli/cli/v2/pkg/cmdutil"
@@ -19,9 +22,21 @@ import (
 
 func NewCmdPull(f *cmdutil.Factory) *cobra.Command {
 	var aId int
+	var assignmentName string
 	var starterFolder string
-	var isAssignmentFolder bool
 	var verbose bool
+	var includeInactive bool
+	var allClassrooms bool
+	var all bool
+	var changedOnly bool
+	var prune bool
+	var pruneDelete bool
+	var yes bool
+	var students []string
+	var selectStudents bool
+	var jobs int
+	var atDeadline bool
+	var at string
 
 	cmd := &cobra.Command{
 		Use:   "pull",
@@ -33,22 +48,89 @@ func NewCmdPull(f *cmdutil.Factory) *cobra.Command {
 
 			This command will:
 			- Clone repositories that don't exist locally
-			- Pull updates for repositories that are already cloned
+			- Pull updates for repositories that are already cloned, keeping local
+			  changes, e.g., annotations, and rolling back pulls that conflict with them
 			- Handle both starter code repository (in a folder named after the classroom) and student repositories
+			- Name the folders of group assignments after the team, or the joined member names
+			- Fall back to the repository name if a folder is already used by another repository
+			- Skip repositories of dropped and auditing students, unless --include-inactive is set
+			- Pull only the repositories of the students given with --student, or
+			  selected interactively with --select-students
 			- Create assignment folder if running from classroom folder
+			- Clone and pull up to --jobs repositories in parallel, printing the
+			  progress and summary in the order of the repositories
+			- Check out each submission at the last commit on the default branch
+			  before the deadline with --at-deadline, or before a timestamp with --at,
+			  in a detached state, recording the commits in .mmc/assignment.json.
+			  Pulling again without these flags returns to the default branch
+			- Print a changelog with the number of new commits since the previous pull,
+			  and the time and subject of the last commit per repository, or only the
+			  repositories with new commits with --changed-only. The tips seen by the
+			  pull are recorded in .mmc/assignment.json
+			- Report clones not backed by an accepted assignment, e.g., of students
+			  removed from the classroom or of deleted repositories, and archive them
+			  to .mmc/archive with --prune, or delete them after confirmation with
+			  --prune --delete
+			- Report GitHub users not in the roster and students who have not accepted
+			  the assignment yet, see gh mmc roster-diff
 
 			The command looks for repositories in the current directory. If a repository 
 			doesn't exist locally, it will be cloned first. If it exists, the latest 
-			changes will be pulled from the default branch.
+			changes will be pulled from the default branch. Local changes, e.g., 
+			annotations, are stashed before and restored after the pull. If the incoming 
+			commits conflict with local commits or changes, the merge is aborted and the 
+			clone is left as it was, reported as CONFLICT. Clones with an unfinished 
+			merge, local changes on a detached HEAD or another branch checked out are 
+			not pulled. Clones with local changes are listed in the summary.
 			
 			The starter code repository will be cloned into a folder named after the classroom.
 			You can override this with the --starter-folder flag.
 			
 			The command can be run within the folder of an assignment, in which case the
 			assignment-id is automatically detected. If the assignment-id is known, it can 
-			be passed as an argument. Otherwise, the user will be prompted to 
-			select a classroom.`),
-		Example: `$ gh mmc pull`,
+			be passed as an argument, or the assignment can be selected by its slug or 
+			title with --assignment. Otherwise, the user will be prompted to select an 
+			assignment, or the command fails with --no-prompt or if stdin is not a 
+			terminal.
+
+			With --all, all assignments of the classroom are pulled into their 
+			assignment folders, creating the folders of new assignments, followed by a 
+			combined summary per assignment.
+
+			With --all-classrooms, the assignment folders of all classrooms below the 
+			workspace folder, marked by gh mmc workspace init, are pulled, followed by 
+			a combined summary. Together with --all, all assignments of all classrooms 
+			are pulled. With --all or --all-classrooms, --select-students prompts once 
+			for the students of each classroom.`),
+		Example: heredoc.Doc(`
+			$ gh mmc pull
+
+			# Pull an assignment by its slug, e.g., in a cron job
+			$ gh mmc pull --assignment html-basics --no-prompt
+
+			# Check out the submissions as they were at the deadline for grading
+			$ gh mmc pull --at-deadline
+
+			# Check out the submissions as they were at a given time
+			$ gh mmc pull --at "2025-03-01 23:59"
+
+			# Pull the repositories of two students only
+			$ gh mmc pull --student janedoe --student "Max Muster"
+
+			# List only the students who pushed new commits since the previous pull
+			$ gh mmc pull --changed-only
+
+			# Archive the clones of students removed from the classroom
+			$ gh mmc pull --prune
+
+			# Clone and pull 8 repositories in parallel
+			$ gh mmc pull --jobs 8
+
+			# Pull all assignments of the classroom
+			$ gh mmc pull --all
+
+			# Pull all assignment folders of all classrooms of the workspace
+			$ gh mmc pull --all-classrooms`),
 		Run: func(cmd *cobra.Command, args []string) {
 			// Save the starting directory to return to it at the end
 			startingDir, err := os.Getwd()
@@ -59,260 +141,1159 @@ func NewCmdPull(f *cmdutil.Factory) *cobra.Command {
 				_ = os.Chdir(startingDir)
 			}()
 
-			client, err := api.DefaultRESTClient()
-			if err != nil {
-				mmc.Fatal(err)
+			if pruneDelete && !prune {
+				mmc.Fatal(fmt.Errorf("--delete can only be used with --prune"))
 			}
 
-			c, err := mmc.LoadClassroom()
-			if err != nil {
-				mmc.Fatal(err)
+			if jobs < 1 {
+				mmc.Fatal(fmt.Errorf("invalid number of jobs %d: must be at least 1", jobs))
 			}
 
-			// Try to find assignment folder (searches upward from current directory)
-			assignmentFolder, err := mmc.FindAssignmentFolder()
-			if err == nil {
-				// We're inside an assignment folder hierarchy
-				isAssignmentFolder = true
-				a, err := mmc.LoadAssignment()
-				if err != nil {
-					mmc.Fatal(err)
+			var cutoff time.Time
+			if at != "" {
+				if atDeadline {
+					mmc.Fatal(fmt.Errorf("--at cannot be used with --at-deadline"))
 				}
-				aId = a.Id
-				// Navigate to the assignment folder root
-				err = os.Chdir(assignmentFolder)
+				cutoff, err = parseCutoff(at)
 				if err != nil {
-					mmc.Fatal(fmt.Errorf("failed to change to assignment directory: %v", err))
+					mmc.Fatal(err)
 				}
-			} else {
-				// Not in assignment folder, check if we're in classroom folder
-				classroomFolder, err := mmc.FindClassroomFolder()
-				if err != nil {
-					mmc.Fatal("No classroom or assignment found. Run `gh mmc init` to initialize a classroom folder or change to a classroom/assignment folder.")
+			}
+
+			client, err := api.DefaultRESTClient()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+
+			opts := pullOptions{
+				aId:             aId,
+				assignmentName:  assignmentName,
+				canPrompt:       f.IOStreams.CanPrompt(),
+				starterFolder:   starterFolder,
+				verbose:         verbose,
+				includeInactive: includeInactive,
+				students:        students,
+				selectStudents:  selectStudents,
+				all:             all,
+				changedOnly:     changedOnly,
+				prune:           prune,
+				pruneDelete:     pruneDelete,
+				yes:             yes,
+				jobs:            jobs,
+				atDeadline:      atDeadline,
+				at:              cutoff,
+			}
+
+			if selectStudents && !f.IOStreams.CanPrompt() {
+				mmc.Fatal(mmc.NoPromptError("--student"))
+			}
+
+			if allClassrooms {
+				if len(students) > 0 {
+					mmc.Fatal(fmt.Errorf("--student cannot be used with --all-classrooms"))
 				}
-				// Navigate to classroom folder root
-				err = os.Chdir(classroomFolder)
-				if err != nil {
-					mmc.Fatal(fmt.Errorf("failed to change to classroom directory: %v", err))
+				if aId != 0 || assignmentName != "" {
+					mmc.Fatal(fmt.Errorf("--assignment-id and --assignment cannot be used with --all-classrooms"))
 				}
-				isAssignmentFolder = false
+				pullAllClassrooms(client, opts)
+				return
 			}
 
-			if aId == 0 {
-				a, err := ghapi.PromptForAssignment(client, c.Classroom.Id)
+			if all {
+				if aId != 0 || assignmentName != "" {
+					mmc.Fatal(fmt.Errorf("--assignment-id and --assignment cannot be used with --all"))
+				}
+				classroomFolder, err := mmc.FindClassroomFolder()
 				if err != nil {
 					mmc.Fatal(err)
 				}
+				printPullSummary(pullAllAssignments(client, classroomFolder, opts))
+				return
+			}
+
+			pullAssignment(client, opts)
+		},
+	}
+
+	cmd.Flags().IntVarP(&aId, "assignment-id", "a", 0, "ID of the assignment")
+	cmd.Flags().StringVar(&assignmentName, "assignment", "", "slug or title of the assignment, instead of its ID")
+	cmd.Flags().StringVarP(&starterFolder, "starter-folder", "s", "", "name of the folder the starter code shall be cloned to (defaults to classroom name)")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose error output")
+	cmd.Flags().BoolVar(&includeInactive, "include-inactive", false, "include repositories of dropped and auditing students")
+	cmd.Flags().StringArrayVarP(&students, "student", "u", nil, "pull only the repositories of the student, given by GitHub user, email, folder name or name (repeatable)")
+	cmd.Flags().BoolVar(&selectStudents, "select-students", false, "select the students to pull the repositories of interactively")
+	cmd.Flags().BoolVar(&allClassrooms, "all-classrooms", false, "pull the assignment folders of all classrooms of the workspace")
+	cmd.Flags().BoolVar(&all, "all", false, "pull all assignments of the classroom, creating the missing assignment folders")
+	cmd.Flags().BoolVar(&changedOnly, "changed-only", false, "list only the repositories with new commits since the previous pull in the changelog")
+	cmd.Flags().BoolVar(&prune, "prune", false, "archive the clones not backed by an accepted assignment to .mmc/archive")
+	cmd.Flags().BoolVar(&pruneDelete, "delete", false, "with --prune, delete the clones after confirmation instead of archiving them")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "delete the clones with --prune --delete without confirmation")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", 1, "number of repositories to clone and pull in parallel")
+	cmd.Flags().BoolVar(&atDeadline, "at-deadline", false, "check out the submissions at the last commit before the deadline of the assignment")
+	cmd.Flags().StringVar(&at, "at", "", "check out the submissions at the last commit before the timestamp, e.g., 2025-03-01T23:59:00+01:00 or \"2025-03-01 23:59\"")
+
+	return cmd
+}
+
+// pullOptions are the flags of the pull command
+type pullOptions struct {
+	aId             int
+	assignmentName  string
+	canPrompt       bool
+	starterFolder   string
+	verbose         bool
+	includeInactive bool
+	students        []string
+	selectStudents  bool
+	all             bool
+	changedOnly     bool
+	prune           bool
+	pruneDelete     bool
+	yes             bool
+	jobs            int
+	atDeadline      bool
+	at              time.Time
+	batch           bool // more than one assignment is pulled
+}
+
+// pullResult summarizes the pull of an assignment
+type pullResult struct {
+	Classroom  string
+	Assignment string
+	Cloned     int
+	Pulled     int
+	Failed     int
+	Skipped    int
+}
+
+// pullAssignment clones and pulls the repositories of the assignment the current
+// directory belongs to, or of the assignment given by the options
+func pullAssignment(client *api.RESTClient, opts pullOptions) pullResult {
+	aId := opts.aId
+	starterFolder := opts.starterFolder
+	verbose := opts.verbose
+	includeInactive := opts.includeInactive
+	var isAssignmentFolder bool
 
-				aId = a.Id
+	// Hold the lock while changing the classroom metadata
+	lock, err := mmc.LockClassroom()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+	defer lock.Unlock() //nolint:errcheck
+
+	c, err := mmc.LoadClassroom()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	classroomFolder, err := mmc.FindClassroomFolder()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	// Store the folder names of students not named yet, e.g., of classrooms
+	// initialized by an older version
+	if n, collisions := c.ResolveFolders(); n > 0 {
+		for _, col := range collisions {
+			fmt.Printf("Folder name %s is not unique, using instead:\n", col.Folder)
+			for _, s := range col.Students {
+				fmt.Printf("  %-30s %s\n", s.Name, s.Folder)
 			}
+		}
+		if err := c.Save(classroomFolder); err != nil {
+			mmc.Fatal(fmt.Errorf("failed to save classroom: %v", err))
+		}
+	}
+
+	// Try to find assignment folder (searches upward from current directory)
+	meta := mmc.NewAssignment()
+	assignmentFolder, err := mmc.FindAssignmentFolder()
+	if err == nil {
+		// We're inside an assignment folder hierarchy
+		isAssignmentFolder = true
+		meta, err = mmc.LoadAssignment()
+		if err != nil {
+			mmc.Fatal(err)
+		}
+		aId = meta.Id
+		// Navigate to the assignment folder root
+		err = os.Chdir(assignmentFolder)
+		if err != nil {
+			mmc.Fatal(fmt.Errorf("failed to change to assignment directory: %v", err))
+		}
+	} else {
+		// Not in assignment folder, navigate to classroom folder root
+		err = os.Chdir(classroomFolder)
+		if err != nil {
+			mmc.Fatal(fmt.Errorf("failed to change to classroom directory: %v", err))
+		}
+		isAssignmentFolder = false
+	}
 
-			assignment, err := ghapi.GetAssignment(client, aId)
+	if aId == 0 {
+		aId = selectAssignment(client, c.Classroom.Id, opts.assignmentName, opts.canPrompt)
+	}
+
+	assignment, err := ghapi.GetAssignment(client, aId)
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	var assignmentPath string
+	if isAssignmentFolder {
+		assignmentPath, err = os.Getwd()
+	} else {
+		assignmentPath, err = filepath.Abs(assignment.Slug)
+	}
+	if err != nil {
+		fmt.Println("Error getting absolute path for directory: ", err)
+		return pullResult{}
+	}
+
+	if !isAssignmentFolder {
+		if _, err := os.Stat(assignmentPath); os.IsNotExist(err) {
+			fmt.Println("Creating directory: ", assignmentPath)
+			err = os.MkdirAll(assignmentPath, 0755)
 			if err != nil {
 				mmc.Fatal(err)
 			}
+		}
 
-			var assignmentPath string
-			if isAssignmentFolder {
-				assignmentPath, err = os.Getwd()
-			} else {
-				assignmentPath, err = filepath.Abs(assignment.Slug)
+		// Change to assignment directory
+		err = os.Chdir(assignmentPath)
+		if err != nil {
+			mmc.Fatal(fmt.Errorf("failed to change to assignment directory: %v", err))
+		}
+
+		// Keep the records of an assignment folder pulled before
+		if existing, err := mmc.LoadAssignment(); err == nil && existing.Id == assignment.Id {
+			meta = existing
+		}
+		meta.Set(assignment.Id, assignment.Slug)
+		err = meta.Save(assignmentPath)
+		if err != nil {
+			mmc.Fatal(err)
+		}
+	}
+
+	acceptedAssignmentList, err := ghapi.ListAllAcceptedAssignments(client, aId, 15)
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	// Check out the submissions at the last commit before the cutoff, if any
+	cutoff := opts.at
+	if opts.atDeadline {
+		if assignment.Deadline == "" {
+			if !opts.batch {
+				mmc.Fatal(fmt.Errorf("assignment %s has no deadline: use --at <timestamp> instead", assignment.Slug))
 			}
+			fmt.Printf("Skipping assignment %s, it has no deadline.\n", assignment.Slug)
+			return pullResult{Classroom: c.Classroom.Name, Assignment: assignment.Slug}
+		}
+		cutoff, err = time.Parse(time.RFC3339, assignment.Deadline)
+		if err != nil {
+			mmc.Fatal(fmt.Errorf("failed to parse deadline %s of assignment %s: %v", assignment.Deadline, assignment.Slug, err))
+		}
+	}
+	if !cutoff.IsZero() {
+		fmt.Printf("Checking out submissions at the last commit before %s\n", cutoff.Local().Format("Mon 2006-01-02 15:04 MST"))
+	}
+
+	totalPulled := 0
+	totalCloned := 0
+	totalCheckedOut := 0
+	pullErrors := []pullFailure{}
+
+	// Get current directory after potential assignment folder creation
+	currentDir, err := os.Getwd()
+	if err != nil {
+		mmc.Fatal(fmt.Errorf("failed to get current directory: %v", err))
+	}
+
+	// Clone starter code repository if it exists and isn't already cloned
+	if assignment.StarterCodeRepository.Id != 0 {
+		if starterFolder == "" {
+			starterFolder = assignment.GitHubClassroom.Name
+		}
+		starterPath := filepath.Join(currentDir, starterFolder)
+
+		if _, err := os.Stat(starterPath); os.IsNotExist(err) {
+			// Starter repo doesn't exist, clone it
+			_, _, err := gh.Exec("repo", "clone", assignment.StarterCodeRepository.FullName, starterFolder)
 			if err != nil {
-				fmt.Println("Error getting absolute path for directory: ", err)
-				return
+				pullErrors = append(pullErrors, pullFailure{"clone", "starter repository " + starterFolder, assignment.StarterCodeRepository.HtmlUrl, err})
+				fmt.Printf("Failed to clone starter repository: %s\n", starterFolder)
+			} else {
+				fmt.Printf("Cloned starter repository: %s (%s)\n", starterFolder, assignment.StarterCodeRepository.HtmlUrl)
+				totalCloned++
 			}
+		} else {
+			// Starter repo exists, pull changes
+			defaultBranch := assignment.StarterCodeRepository.DefaultBranch
+			if defaultBranch == "" {
+				defaultBranch = "main" // fallback to main if not specified
+			}
+			if _, err := pullRepository(starterPath, defaultBranch); err != nil {
+				pullErrors = append(pullErrors, pullFailure{"pull", "starter repository " + starterFolder, assignment.StarterCodeRepository.HtmlUrl, err})
+				fmt.Printf("Failed to pull starter repository: %s\n", starterFolder)
+			} else {
+				fmt.Printf("Pulled starter repository: %s (%s)\n", starterFolder, assignment.StarterCodeRepository.HtmlUrl)
+				totalPulled++
+			}
+		}
+	}
 
-			if !isAssignmentFolder {
-				if _, err := os.Stat(assignmentPath); os.IsNotExist(err) {
-					fmt.Println("Creating directory: ", assignmentPath)
-					err = os.MkdirAll(assignmentPath, 0755)
-					if err != nil {
-						mmc.Fatal(err)
-					}
-				}
+	// Store the GitHub user ids and follow renamed GitHub users of the students
+	classroomChanged := false
+	var submitters []mmc.Submitter
+	for _, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
+		for _, s := range acceptedAssignment.Students {
+			renamedFrom, changed := c.UpdateGithubUser(s.Id, s.Login)
+			if renamedFrom != "" {
+				fmt.Printf("GitHub user %s has been renamed to %s\n", renamedFrom, s.Login)
+			}
+			classroomChanged = classroomChanged || changed
+			submitters = append(submitters, mmc.Submitter{Id: s.Id, Login: s.Login})
+		}
+	}
+	if classroomChanged {
+		if err := c.Save(classroomFolder); err != nil {
+			mmc.Fatal(fmt.Errorf("failed to save classroom: %v", err))
+		}
+	}
 
-				a := mmc.NewAssignment()
-				a.Set(assignment.Id, assignment.Slug)
-				err = a.Save(assignmentPath)
-				if err != nil {
-					mmc.Fatal(err)
-				}
+	// Limit the repositories to the selected students, if any
+	selected, err := c.FindStudents(opts.students)
+	if opts.selectStudents {
+		selected, err = c.PromptForStudents()
+	}
+	if err != nil {
+		mmc.Fatal(err)
+	}
 
-				// Change to assignment directory
-				err = os.Chdir(assignmentPath)
-				if err != nil {
-					mmc.Fatal(fmt.Errorf("failed to change to assignment directory: %v", err))
-				}
+	// Skip the repositories of dropped and auditing students, unless selected
+	skipped := 0
+	acceptedAssignments := []ghapi.GitHubAcceptedAssignment{}
+	for _, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
+		if selected != nil {
+			if c.IsSubmittedBy(acceptedAssignment.Logins(), selected) {
+				acceptedAssignments = append(acceptedAssignments, acceptedAssignment)
 			}
+			continue
+		}
+		if !includeInactive && !c.IsActiveSubmitter(acceptedAssignment.Logins()) {
+			skipped++
+			continue
+		}
+		acceptedAssignments = append(acceptedAssignments, acceptedAssignment)
+	}
 
-			acceptedAssignmentList, err := ghapi.ListAllAcceptedAssignments(client, aId, 15)
-			if err != nil {
-				mmc.Fatal(err)
+	fmt.Printf("Processing %d student repositories...\n\n", len(acceptedAssignments))
+
+	// Folders already used in this run, to never pull a repository into the clone of another
+	usedFolders := map[string]string{strings.ToLower(starterFolder): assignment.StarterCodeRepository.FullName}
+
+	// Name the folders in the order of the accepted assignments, so the folders do
+	// not depend on the order the repositories are processed in
+	jobs := make([]pullJob, 0, len(acceptedAssignments))
+	for _, acceptedAssignment := range acceptedAssignments {
+		isGroup := assignment.IsGroup() || len(acceptedAssignment.Students) > 1
+		repoName := c.SubmissionFolder(acceptedAssignment.Repository.Name, assignment.Slug, isGroup, acceptedAssignment.Logins())
+		if other, ok := usedFolders[strings.ToLower(repoName)]; ok && other != acceptedAssignment.Repository.FullName {
+			fmt.Printf("Folder %s is already used by %s, using %s instead\n", repoName, other, acceptedAssignment.Repository.Name)
+			repoName = acceptedAssignment.Repository.Name
+		}
+		usedFolders[strings.ToLower(repoName)] = acceptedAssignment.Repository.FullName
+		if isGroup {
+			meta.SetTeam(mmc.TeamName(acceptedAssignment.Repository.Name, assignment.Slug), repoName, acceptedAssignment.Logins())
+		}
+
+		defaultBranch := acceptedAssignment.Repository.DefaultBranch
+		if defaultBranch == "" {
+			defaultBranch = "main" // fallback to main if not specified
+		}
+		jobs = append(jobs, pullJob{
+			repoName:      repoName,
+			repoPath:      filepath.Join(currentDir, repoName),
+			fullName:      acceptedAssignment.Repository.FullName,
+			htmlUrl:       acceptedAssignment.Repository.HtmlUrl,
+			defaultBranch: defaultBranch,
+			cutoff:        cutoff,
+			lastSeen:      meta.LastSeenSha(repoName, acceptedAssignment.Repository.FullName),
+		})
+	}
+
+	// Clones with local changes, e.g., annotations of the teacher, kept across the pull
+	type dirtyClone struct {
+		repoName string
+		changes  []string
+	}
+	var dirty []dirtyClone
+
+	// The new commits per repository since the previous pull
+	type changelogEntry struct {
+		repoName  string
+		changelog pullChangelog
+	}
+	var changelog []changelogEntry
+	pulledAt := time.Now()
+
+	// Process the repositories in parallel, printing the results in order
+	results := runPullJobs(jobs, opts.jobs)
+	for i, job := range jobs {
+		fmt.Printf("[%d/%d] Processing %s...", i+1, len(jobs), job.repoName)
+
+		r := <-results[i]
+		if len(r.changes) > 0 {
+			dirty = append(dirty, dirtyClone{job.repoName, r.changes})
+		}
+		if r.changelog.sha != "" {
+			changelog = append(changelog, changelogEntry{job.repoName, r.changelog})
+			meta.SetLastSeen(job.repoName, job.fullName, r.changelog.sha, pulledAt)
+		}
+		if r.err != nil {
+			failure := pullFailure{r.action, job.repoName, job.htmlUrl, r.err}
+			pullErrors = append(pullErrors, failure)
+			state := "FAILED"
+			if r.action == "merge" {
+				state = "CONFLICT"
 			}
+			if verbose {
+				fmt.Printf(" %s\n%s\n", state, failure)
+			} else {
+				fmt.Printf(" %s\n", state)
+			}
+			continue
+		}
 
-			totalPulled := 0
-			totalCloned := 0
-			pullErrors := []string{}
+		at := ""
+		if r.sha != "" {
+			at = " at " + r.sha[:7]
+			meta.SetSnapshot(job.repoName, job.fullName, cutoff, r.sha)
+			totalCheckedOut++
+		}
+		if r.action == "clone" {
+			fmt.Printf(" CLONED%s\n", at)
+			totalCloned++
+		} else {
+			fmt.Printf(" PULLED%s\n", at)
+			totalPulled++
+		}
+	}
 
-			// Get current directory after potential assignment folder creation
-			currentDir, err := os.Getwd()
-			if err != nil {
-				mmc.Fatal(fmt.Errorf("failed to get current directory: %v", err))
+	// Print the new commits per repository since the previous pull
+	changed := 0
+	for _, e := range changelog {
+		if e.changelog.newCommits > 0 || e.changelog.rewritten {
+			changed++
+		}
+	}
+	if opts.changedOnly && changed == 0 {
+		fmt.Println("\nNo new commits since the previous pull.")
+	} else if len(changelog) > 0 {
+		fmt.Printf("\nChanges since the previous pull (%d of %d repositories with new commits):\n", changed, len(changelog))
+		fmt.Printf("%-35s %9s  %-16s  %s\n", "FOLDER", "NEW", "LAST COMMIT", "SUBJECT")
+		for _, e := range changelog {
+			l := e.changelog
+			if opts.changedOnly && l.newCommits == 0 && !l.rewritten {
+				continue
+			}
+			newCommits := strconv.Itoa(l.newCommits)
+			if l.rewritten {
+				newCommits = "rewritten"
+			}
+			subject := l.subject
+			if r := []rune(subject); len(r) > 50 {
+				subject = string(r[:47]) + "..."
 			}
+			fmt.Printf("%-35s %9s  %-16s  %s\n", e.repoName, newCommits, l.lastCommit.Local().Format("2006-01-02 15:04"), subject)
+		}
+	}
 
-			// Clone starter code repository if it exists and isn't already cloned
-			if assignment.StarterCodeRepository.Id != 0 {
-				if starterFolder == "" {
-					starterFolder = assignment.GitHubClassroom.Name
-				}
-				starterPath := filepath.Join(currentDir, starterFolder)
-
-				if _, err := os.Stat(starterPath); os.IsNotExist(err) {
-					// Starter repo doesn't exist, clone it
-					_, _, err := gh.Exec("repo", "clone", assignment.StarterCodeRepository.FullName, starterFolder)
-					if err != nil {
-						errMsg := fmt.Sprintf("Failed to clone starter repository %s (%s): %v", starterFolder, assignment.StarterCodeRepository.HtmlUrl, err)
-						pullErrors = append(pullErrors, errMsg)
-						fmt.Printf("Failed to clone starter repository: %s\n", starterFolder)
-					} else {
-						fmt.Printf("Cloned starter repository: %s (%s)\n", starterFolder, assignment.StarterCodeRepository.HtmlUrl)
-						totalCloned++
-					}
-				} else {
-					// Starter repo exists, pull changes
-					defaultBranch := assignment.StarterCodeRepository.DefaultBranch
-					if defaultBranch == "" {
-						defaultBranch = "main" // fallback to main if not specified
-					}
-					if err := pullRepository(starterPath, defaultBranch); err != nil {
-						errMsg := fmt.Sprintf("Failed to pull starter repository %s (%s): %v", starterFolder, assignment.StarterCodeRepository.HtmlUrl, err)
-						pullErrors = append(pullErrors, errMsg)
-						fmt.Printf("Failed to pull starter repository: %s\n", starterFolder)
-					} else {
-						fmt.Printf("Pulled starter repository: %s (%s)\n", starterFolder, assignment.StarterCodeRepository.HtmlUrl)
-						totalPulled++
-					}
+	// Find the clones of students removed from the classroom or of deleted repositories
+	keep := map[string]bool{strings.ToLower(starterFolder): true}
+	for _, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
+		isGroup := assignment.IsGroup() || len(acceptedAssignment.Students) > 1
+		keep[strings.ToLower(c.SubmissionFolder(acceptedAssignment.Repository.Name, assignment.Slug, isGroup, acceptedAssignment.Logins()))] = true
+		keep[strings.ToLower(acceptedAssignment.Repository.Name)] = true
+	}
+	stale, err := findStaleClones(currentDir, keep)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if len(stale) > 0 {
+		fmt.Printf("\n%d clones are not backed by an accepted assignment:\n", len(stale))
+		for _, folder := range stale {
+			fmt.Printf("  - %s\n", folder)
+		}
+		if opts.prune {
+			pruneClones(currentDir, stale, meta.Forget, opts)
+		} else {
+			fmt.Println("Run with --prune to archive them to .mmc/archive, or with --prune --delete to delete them.")
+		}
+	}
+
+	// Record the teams of group assignments for other commands, e.g., check, the
+	// commits the submissions have been checked out at, and the tips seen by the pull,
+	// forgetting the pruned clones
+	if len(meta.Teams) > 0 || len(meta.Snapshots) > 0 || len(meta.LastSeen) > 0 || (opts.prune && len(stale) > 0) {
+		if err := meta.Save(currentDir); err != nil {
+			fmt.Printf("Failed to save assignment: %v\n", err)
+		}
+	}
+
+	if len(pullErrors) > 0 {
+		fmt.Printf("\n%d repositories failed to pull/clone:\n", len(pullErrors))
+		if !verbose {
+			fmt.Println("Run with --verbose flag to see detailed error messages")
+			for _, failure := range pullErrors {
+				fmt.Printf("  - %s (%s)\n", failure.repoName, failure.reason())
+			}
+		} else {
+			for _, failure := range pullErrors {
+				fmt.Printf("  %s\n", failure)
+			}
+		}
+		fmt.Printf("\nResults: %d cloned, %d pulled, %d failed out of %d total repositories.\n",
+			totalCloned, totalPulled, len(pullErrors), totalCloned+totalPulled+len(pullErrors))
+	} else {
+		fmt.Printf("\nSuccessfully processed all %d repositories (%d cloned, %d pulled).\n",
+			totalCloned+totalPulled, totalCloned, totalPulled)
+	}
+	if !cutoff.IsZero() {
+		fmt.Printf("Checked out %d submissions at the last commit before %s, recorded in %s.\n",
+			totalCheckedOut, cutoff.Local().Format("Mon 2006-01-02 15:04 MST"), filepath.Join(".mmc", "assignment.json"))
+	}
+	if len(dirty) > 0 {
+		fmt.Printf("\n%d clones have local changes, kept across the pull:\n", len(dirty))
+		for _, d := range dirty {
+			fmt.Printf("  - %s (%d changed files)\n", d.repoName, len(d.changes))
+			if verbose {
+				for _, file := range d.changes {
+					fmt.Printf("      %s\n", file)
 				}
 			}
+		}
+	}
+	if skipped > 0 {
+		fmt.Printf("Skipped %d repositories of inactive students, use --include-inactive to include them.\n", skipped)
+	}
+
+	// Report submitters not in the roster and students who have not accepted yet
+	report := c.CompareSubmitters(submitters)
+	if selected != nil {
+		report = report.ForStudents(selected)
+	}
+	report.Print()
 
-			fmt.Printf("Processing %d student repositories...\n\n", len(acceptedAssignmentList.AcceptedAssignments))
+	return pullResult{
+		Classroom:  c.Classroom.Name,
+		Assignment: assignment.Slug,
+		Cloned:     totalCloned,
+		Pulled:     totalPulled,
+		Failed:     len(pullErrors),
+		Skipped:    skipped,
+	}
+}
 
-			for i, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
-				repoName := acceptedAssignment.Repository.Name
-				if len(acceptedAssignment.Students) == 1 {
-					if name, err := c.GetRepoName(acceptedAssignment.Students[0].Login); err == nil {
-						repoName = name
-					}
-				}
+// pullAllClassrooms pulls the assignment folders of all classrooms of the workspace
+func pullAllClassrooms(client *api.RESTClient, opts pullOptions) {
+	classroomFolders, err := mmc.WorkspaceClassroomFolders()
+	if err != nil {
+		mmc.Fatal(err)
+	}
 
-				fmt.Printf("[%d/%d] Processing %s...", i+1, len(acceptedAssignmentList.AcceptedAssignments), repoName)
-
-				repoPath := filepath.Join(currentDir, repoName)
-
-				// Check if repository directory exists
-				if _, err := os.Stat(repoPath); os.IsNotExist(err) {
-					// Repository doesn't exist, clone it
-					_, _, err := gh.Exec("repo", "clone", acceptedAssignment.Repository.FullName, repoName)
-					if err != nil {
-						errMsg := fmt.Sprintf("Failed to clone %s (%s): %v", repoName, acceptedAssignment.Repository.HtmlUrl, err)
-						pullErrors = append(pullErrors, errMsg)
-						if verbose {
-							fmt.Printf(" FAILED\n%s\n", errMsg)
-						} else {
-							fmt.Printf(" FAILED\n")
-						}
-						continue
-					}
-					fmt.Printf(" CLONED\n")
-					totalCloned++
-				} else {
-					// Repository exists, pull changes
-					defaultBranch := acceptedAssignment.Repository.DefaultBranch
-					if defaultBranch == "" {
-						defaultBranch = "main" // fallback to main if not specified
-					}
-					if err := pullRepository(repoPath, defaultBranch); err != nil {
-						errMsg := fmt.Sprintf("Failed to pull %s (%s): %v", repoName, acceptedAssignment.Repository.HtmlUrl, err)
-						pullErrors = append(pullErrors, errMsg)
-						if verbose {
-							fmt.Printf(" FAILED\n%s\n", errMsg)
-						} else {
-							fmt.Printf(" FAILED\n")
-						}
-						continue
-					}
-
-					fmt.Printf(" PULLED\n")
-					totalPulled++
-				}
+	opts.batch = true
+
+	var results []pullResult
+	for _, classroomFolder := range classroomFolders {
+		if opts.all {
+			results = append(results, pullAllAssignments(client, classroomFolder, opts)...)
+			continue
+		}
+
+		assignmentFolders, err := mmc.ListAssignmentFolders(classroomFolder)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		if len(assignmentFolders) == 0 {
+			fmt.Printf("\nNo assignment folders found in %s, run gh mmc pull in the classroom folder first.\n", classroomFolder)
+			continue
+		}
+
+		classroomOpts := selectClassroomStudents(classroomFolder, opts)
+		for _, assignmentFolder := range assignmentFolders {
+			fmt.Printf("\n=== %s ===\n\n", assignmentFolder)
+			if err := os.Chdir(assignmentFolder); err != nil {
+				mmc.Fatal(fmt.Errorf("failed to change to assignment directory: %v", err))
 			}
+			results = append(results, pullAssignment(client, classroomOpts))
+		}
+	}
 
-			if len(pullErrors) > 0 {
-				fmt.Printf("\n%d repositories failed to pull/clone:\n", len(pullErrors))
-				if !verbose {
-					fmt.Println("Run with --verbose flag to see detailed error messages")
-					for _, errMsg := range pullErrors {
-						// Extract just the repo name from the error message for summary
-						if strings.Contains(errMsg, "Failed to clone ") {
-							prefix := "Failed to clone "
-							if len(errMsg) > len(prefix) && errMsg[:len(prefix)] == prefix {
-								remaining := errMsg[len(prefix):]
-								if parenIdx := strings.Index(remaining, " ("); parenIdx > 0 {
-									repoName := remaining[:parenIdx]
-									fmt.Printf("  - %s (clone failed)\n", repoName)
-								}
-							}
-						} else if strings.Contains(errMsg, "Failed to pull ") {
-							prefix := "Failed to pull "
-							if len(errMsg) > len(prefix) && errMsg[:len(prefix)] == prefix {
-								remaining := errMsg[len(prefix):]
-								if parenIdx := strings.Index(remaining, " ("); parenIdx > 0 {
-									repoName := remaining[:parenIdx]
-									fmt.Printf("  - %s (pull failed)\n", repoName)
-								}
-							}
-						}
-					}
-				} else {
-					for _, errMsg := range pullErrors {
-						fmt.Printf("  %s\n", errMsg)
-					}
-				}
-				fmt.Printf("\nResults: %d cloned, %d pulled, %d failed out of %d total repositories.\n",
-					totalCloned, totalPulled, len(pullErrors), totalCloned+totalPulled+len(pullErrors))
-			} else {
-				fmt.Printf("\nSuccessfully processed all %d repositories (%d cloned, %d pulled).\n",
-					totalCloned+totalPulled, totalCloned, totalPulled)
+	printPullSummary(results)
+}
+
+// pullAllAssignments clones and pulls all assignments of the classroom of the classroom
+// folder into their assignment folders, creating the missing ones, and returns the
+// results in the order of the assignments
+func pullAllAssignments(client *api.RESTClient, classroomFolder string, opts pullOptions) []pullResult {
+	opts = selectClassroomStudents(classroomFolder, opts)
+	if err := os.Chdir(classroomFolder); err != nil {
+		mmc.Fatal(fmt.Errorf("failed to change to classroom directory: %v", err))
+	}
+	c, err := mmc.LoadClassroom()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	assignments, err := ghapi.ListAllAssignments(client, c.Classroom.Id)
+	if err != nil {
+		mmc.Fatal(fmt.Errorf("failed to get classroom assignments: %v", err))
+	}
+	if len(assignments) == 0 {
+		fmt.Printf("No assignments found in classroom %s.\n", c.Classroom.Name)
+		return nil
+	}
+
+	// Find the existing assignment folders by assignment id, as they may have been renamed
+	assignmentFolders, err := mmc.ListAssignmentFolders(classroomFolder)
+	if err != nil {
+		mmc.Fatal(err)
+	}
+	folders := map[int]string{}
+	for _, assignmentFolder := range assignmentFolders {
+		if err := os.Chdir(assignmentFolder); err != nil {
+			mmc.Fatal(fmt.Errorf("failed to change to assignment directory: %v", err))
+		}
+		a, err := mmc.LoadAssignment()
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		folders[a.Id] = assignmentFolder
+	}
+
+	var results []pullResult
+	for _, assignment := range assignments {
+		fmt.Printf("\n=== %s (%s) ===\n\n", assignment.Title, c.Classroom.Name)
+
+		// Pull into the existing assignment folder, or create it from the classroom folder
+		folder, ok := folders[assignment.Id]
+		if !ok {
+			folder = classroomFolder
+		}
+		if err := os.Chdir(folder); err != nil {
+			mmc.Fatal(fmt.Errorf("failed to change to directory %s: %v", folder, err))
+		}
+
+		assignmentOpts := opts
+		assignmentOpts.aId = assignment.Id
+		assignmentOpts.batch = true
+		results = append(results, pullAssignment(client, assignmentOpts))
+	}
+
+	return results
+}
+
+// selectClassroomStudents prompts once for the students of the classroom in the
+// classroom folder, so that the pulls of all its assignments are limited to them
+func selectClassroomStudents(classroomFolder string, opts pullOptions) pullOptions {
+	if !opts.selectStudents {
+		return opts
+	}
+	if err := os.Chdir(classroomFolder); err != nil {
+		mmc.Fatal(fmt.Errorf("failed to change to classroom directory: %v", err))
+	}
+	c, err := mmc.LoadClassroom()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	fmt.Printf("\nStudents of classroom %s\n", c.Classroom.Name)
+	selected, err := c.PromptForStudents()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	// Each assignment reloads the classroom, so pass the selection on by email
+	opts.selectStudents = false
+	opts.students = nil
+	for _, s := range selected {
+		opts.students = append(opts.students, s.Email)
+	}
+	return opts
+}
+
+// findStaleClones returns the clones directly below the assignment folder whose folder
+// is not kept, sorted by name. Hidden folders and folders that are no clones, e.g.,
+// notes of the teacher, are never returned.
+func findStaleClones(assignmentFolder string, keep map[string]bool) ([]string, error) {
+	entries, err := os.ReadDir(assignmentFolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read assignment directory: %v", err)
+	}
+
+	var stale []string
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") || keep[strings.ToLower(e.Name())] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(assignmentFolder, e.Name(), ".git")); err != nil {
+			continue
+		}
+		stale = append(stale, e.Name())
+	}
+	return stale, nil
+}
+
+// pruneClones archives the stale clones to .mmc/archive of the assignment folder, or
+// deletes them after confirmation with --delete, and forgets their records
+func pruneClones(assignmentFolder string, stale []string, forget func(folder string), opts pullOptions) {
+	if opts.pruneDelete && !opts.yes && !mmc.Confirm(opts.canPrompt, fmt.Sprintf("\nDelete %d clones including their local changes?", len(stale))) {
+		fmt.Println("Pruning cancelled.")
+		return
+	}
+
+	archivedAt := time.Now()
+	pruned := 0
+	for _, folder := range stale {
+		if opts.pruneDelete {
+			if err := os.RemoveAll(filepath.Join(assignmentFolder, folder)); err != nil {
+				fmt.Printf("Failed to delete %s: %v\n", folder, err)
+				continue
 			}
-		},
+			fmt.Printf("Deleted: %s\n", folder)
+		} else {
+			to, err := mmc.ArchiveFolder(assignmentFolder, folder, archivedAt)
+			if err != nil {
+				fmt.Printf("Failed to archive %s: %v\n", folder, err)
+				continue
+			}
+			rel, err := filepath.Rel(assignmentFolder, to)
+			if err != nil {
+				rel = to
+			}
+			fmt.Printf("Archived: %s to %s\n", folder, rel)
+		}
+		forget(folder)
+		pruned++
 	}
+	fmt.Printf("Pruned %d out of %d clones.\n", pruned, len(stale))
+}
 
-	cmd.Flags().IntVarP(&aId, "assignment-id", "a", 0, "ID of the assignment")
-	cmd.Flags().StringVarP(&starterFolder, "starter-folder", "s", "", "name of the folder the starter code shall be cloned to (defaults to classroom name)")
-	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose error output")
+// printPullSummary prints the combined results of several assignments
+func printPullSummary(results []pullResult) {
+	var total pullResult
+	fmt.Printf("\n%-30s %-30s %8s %8s %8s %8s\n", "CLASSROOM", "ASSIGNMENT", "CLONED", "PULLED", "FAILED", "SKIPPED")
+	for _, r := range results {
+		fmt.Printf("%-30s %-30s %8d %8d %8d %8d\n", r.Classroom, r.Assignment, r.Cloned, r.Pulled, r.Failed, r.Skipped)
+		total.Cloned += r.Cloned
+		total.Pulled += r.Pulled
+		total.Failed += r.Failed
+		total.Skipped += r.Skipped
+	}
+	fmt.Printf("%-30s %-30s %8d %8d %8d %8d\n", "TOTAL", fmt.Sprintf("%d assignments", len(results)), total.Cloned, total.Pulled, total.Failed, total.Skipped)
+}
 
-	return cmd
+// pullFailure is a repository that failed to clone, pull, merge or check out
+type pullFailure struct {
+	action   string
+	repoName string
+	htmlUrl  string
+	err      error
+}
+
+func (f pullFailure) String() string {
+	return fmt.Sprintf("Failed to %s %s (%s): %v", f.action, f.repoName, f.htmlUrl, f.err)
+}
+
+// reason returns a short description of the failure for the summary
+func (f pullFailure) reason() string {
+	if f.action == "merge" {
+		return "conflict, rolled back"
+	}
+	return f.action + " failed"
+}
+
+// pullConflictError reports a pull that has been rolled back, as the incoming commits
+// conflict with the local commits or changes of the clone
+type pullConflictError struct {
+	files []string
 }
 
-// pullRepository safely pulls a repository using autostash to preserve local changes
-// This ensures we get the latest content while preserving any uncommitted student work
-func pullRepository(repoPath, defaultBranch string) error {
+func (e *pullConflictError) Error() string {
+	return fmt.Sprintf("incoming commits conflict with local changes in %s, pull rolled back", strings.Join(e.files, ", "))
+}
+
+// pullRepository safely pulls the default branch of a repository. Local changes, e.g.,
+// annotations of the teacher, are stashed and restored after the pull and returned. If
+// the incoming commits conflict with the local commits or changes, the clone is rolled
+// back to its state before the pull and a pullConflictError is returned.
+func pullRepository(repoPath, defaultBranch string) ([]string, error) {
 	// Verify it's a git repository
 	gitDir := filepath.Join(repoPath, ".git")
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-		return fmt.Errorf("not a git repository")
+		return nil, fmt.Errorf("not a git repository")
+	}
+
+	// Never pull into an unfinished merge or rebase
+	for _, name := range []string{"MERGE_HEAD", "rebase-merge", "rebase-apply"} {
+		if _, err := os.Stat(filepath.Join(gitDir, name)); err == nil {
+			return nil, fmt.Errorf("unfinished merge or rebase, finish or abort it first")
+		}
+	}
+
+	// Collect the local changes, including untracked files. Paths are unquoted with -z,
+	// and renames and copies are followed by their original path, which is skipped.
+	out, err := runGit(repoPath, "status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	entries := splitPaths(out)
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		changes = append(changes, entry[3:])
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+	}
+
+	// Return to the default branch if HEAD is detached, e.g., after pull --at-deadline,
+	// unless local changes would be carried along
+	branch, err := runGit(repoPath, "symbolic-ref", "-q", "--short", "HEAD")
+	if err != nil {
+		if len(changes) > 0 {
+			return changes, fmt.Errorf("detached HEAD with local changes, commit or stash them and check out %s first", defaultBranch)
+		}
+		if _, err := runGit(repoPath, "checkout", defaultBranch); err != nil {
+			return changes, err
+		}
+	} else if branch != defaultBranch {
+		return changes, fmt.Errorf("on branch %s instead of %s, check out %s first", branch, defaultBranch, defaultBranch)
+	}
+
+	// Fetch the default branch, and track it if the upstream is missing
+	if _, err := runGit(repoPath, "remote", "get-url", "origin"); err != nil {
+		return changes, fmt.Errorf("no remote origin")
+	}
+	if _, err := runGit(repoPath, "fetch", "origin", defaultBranch); err != nil {
+		return changes, err
+	}
+	upstream := "origin/" + defaultBranch
+	if _, err := runGit(repoPath, "rev-parse", "--verify", "-q", "refs/remotes/"+upstream); err != nil {
+		return changes, fmt.Errorf("no upstream branch %s", upstream)
+	}
+	if _, err := runGit(repoPath, "rev-parse", "-q", "--abbrev-ref", "@{upstream}"); err != nil {
+		if _, err := runGit(repoPath, "branch", "--set-upstream-to="+upstream); err != nil {
+			return changes, err
+		}
+	}
+
+	// Nothing to merge if the clone is up to date
+	if _, err := runGit(repoPath, "merge-base", "--is-ancestor", upstream, "HEAD"); err == nil {
+		return changes, nil
+	}
+
+	// Leave the clone untouched if the incoming commits change locally changed files
+	out, err = runGit(repoPath, "diff", "--name-only", "-z", "HEAD..."+upstream)
+	if err != nil {
+		return changes, err
+	}
+	incoming := map[string]bool{}
+	for _, file := range splitPaths(out) {
+		incoming[file] = true
+	}
+	var conflicts []string
+	for _, file := range changes {
+		if incoming[file] {
+			conflicts = append(conflicts, file)
+		}
+	}
+	if len(conflicts) > 0 {
+		return changes, &pullConflictError{files: conflicts}
+	}
+
+	head, err := runGit(repoPath, "rev-parse", "HEAD")
+	if err != nil {
+		return changes, err
 	}
 
-	// Pull with autostash - this handles fetch, merge, and stashing automatically
-	pullCmd := exec.Command("git", "pull", "--autostash", "origin", defaultBranch)
-	pullCmd.Dir = repoPath
-	var pullOut bytes.Buffer
-	pullCmd.Stdout = &pullOut
-	pullCmd.Stderr = &pullOut
-	if err := pullCmd.Run(); err != nil {
-		return fmt.Errorf("git pull failed: %v\nOutput: %s", err, pullOut.String())
+	// Stash the local changes, so they cannot get in the way of the merge
+	stashed := false
+	if len(changes) > 0 {
+		if _, err := runGit(repoPath, "stash", "push", "--include-untracked", "-m", "gh mmc pull"); err != nil {
+			return changes, err
+		}
+		stashed = true
 	}
 
+	// Merge the incoming commits, aborting the merge on conflicts
+	if _, mergeErr := runGit(repoPath, "merge", "--no-edit", upstream); mergeErr != nil {
+		out, _ := runGit(repoPath, "diff", "--name-only", "-z", "--diff-filter=U")
+		if _, err := os.Stat(filepath.Join(gitDir, "MERGE_HEAD")); err == nil {
+			if _, err := runGit(repoPath, "merge", "--abort"); err != nil {
+				return changes, fmt.Errorf("%v\nfailed to abort the merge: %v", mergeErr, err)
+			}
+		}
+		if err := restoreStash(repoPath, head, stashed); err != nil {
+			return changes, err
+		}
+		if files := splitPaths(out); len(files) > 0 {
+			return changes, &pullConflictError{files: files}
+		}
+		return changes, mergeErr
+	}
+
+	// Restore the local changes, rolling back the merge if they do not apply
+	if stashed {
+		if _, popErr := runGit(repoPath, "stash", "pop"); popErr != nil {
+			out, _ := runGit(repoPath, "diff", "--name-only", "-z", "--diff-filter=U")
+			if _, err := runGit(repoPath, "reset", "--hard", head); err != nil {
+				return changes, fmt.Errorf("failed to roll back the pull, the local changes are kept in the stash: %v", err)
+			}
+			if err := restoreStash(repoPath, head, true); err != nil {
+				return changes, err
+			}
+			if files := splitPaths(out); len(files) > 0 {
+				return changes, &pullConflictError{files: files}
+			}
+			return changes, popErr
+		}
+	}
+
+	return changes, nil
+}
+
+// restoreStash restores the local changes stashed before a pull on top of the commit
+// the clone has been at before the pull. If they do not apply, they are kept in the stash.
+func restoreStash(repoPath, head string, stashed bool) error {
+	if !stashed {
+		return nil
+	}
+	if _, err := runGit(repoPath, "stash", "pop"); err != nil {
+		return fmt.Errorf("failed to restore the local changes, they are kept in the stash, run git stash pop at %s: %v", head[:7], err)
+	}
 	return nil
 }
+
+// runGit runs git in the repository and returns its output without the trailing newline
+func runGit(repoPath string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoPath
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %v\nOutput: %s%s", args[0], err, out.String(), errOut.String())
+	}
+	return strings.TrimRight(out.String(), "\n"), nil
+}
+
+// splitPaths splits the NUL terminated output of a git command run with -z
+func splitPaths(out string) []string {
+	var paths []string
+	for _, p := range strings.Split(out, "\x00") {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// pullJob is a repository to clone or pull
+type pullJob struct {
+	repoName      string
+	repoPath      string
+	fullName      string
+	htmlUrl       string
+	defaultBranch string
+	cutoff        time.Time
+	lastSeen      string
+}
+
+// pullJobResult is the outcome of a pull job, the action being clone, pull, merge or
+// check out, the SHA of the commit checked out at the cutoff of the job, the local
+// changes of the clone and the new commits since the last pull
+type pullJobResult struct {
+	action    string
+	sha       string
+	changes   []string
+	changelog pullChangelog
+	err       error
+}
+
+// runPullJobs clones or pulls the repositories of the jobs with n workers. It returns
+// a channel per job receiving its result, so the results can be read in order.
+func runPullJobs(jobs []pullJob, n int) []chan pullJobResult {
+	results := make([]chan pullJobResult, len(jobs))
+	for i := range results {
+		results[i] = make(chan pullJobResult, 1)
+	}
+
+	queue := make(chan int)
+	for w := 0; w < n; w++ {
+		go func() {
+			for i := range queue {
+				results[i] <- runPullJob(jobs[i])
+			}
+		}()
+	}
+	go func() {
+		for i := range jobs {
+			queue <- i
+		}
+		close(queue)
+	}()
+
+	return results
+}
+
+// runPullJob clones the repository if it does not exist locally, or pulls it otherwise,
+// and checks out the last commit before the cutoff of the job, if any
+func runPullJob(job pullJob) pullJobResult {
+	r := pullJobResult{action: "pull"}
+
+	// Check if repository directory exists
+	if _, err := os.Stat(job.repoPath); os.IsNotExist(err) {
+		// Repository doesn't exist, clone it into the directory of the job
+		r.action = "clone"
+		_, _, r.err = gh.Exec("repo", "clone", job.fullName, job.repoPath)
+	} else {
+		// Repository exists, pull changes
+		r.changes, r.err = pullRepository(job.repoPath, job.defaultBranch)
+		var conflict *pullConflictError
+		if errors.As(r.err, &conflict) {
+			r.action = "merge"
+		}
+	}
+	if r.err != nil {
+		return r
+	}
+
+	// The changelog is informational only, so failing to read it does not fail the job
+	r.changelog, _ = readChangelog(job.repoPath, job.defaultBranch, job.lastSeen)
+	if job.cutoff.IsZero() {
+		return r
+	}
+
+	sha, err := checkoutAt(job.repoPath, job.defaultBranch, job.cutoff)
+	if err != nil {
+		return pullJobResult{action: "check out", changes: r.changes, changelog: r.changelog, err: err}
+	}
+	r.sha = sha
+	return r
+}
+
+// pullChangelog describes the default branch of a clone after a pull: its tip, the
+// number of new commits since the last seen tip and the last commit. The history has
+// been rewritten if the last seen tip is no longer on the default branch.
+type pullChangelog struct {
+	sha        string
+	newCommits int
+	rewritten  bool
+	lastCommit time.Time
+	subject    string
+}
+
+// readChangelog reads the changes of the default branch of a clone since the last seen
+// tip. All commits are new if the last seen tip is unknown.
+func readChangelog(repoPath, defaultBranch, lastSeen string) (pullChangelog, error) {
+	var l pullChangelog
+
+	sha, err := runGit(repoPath, "rev-parse", "--verify", "-q", "refs/heads/"+defaultBranch)
+	if err != nil {
+		return l, err
+	}
+
+	out, err := runGit(repoPath, "log", "-1", "--format=%cI%x00%s", sha)
+	if err != nil {
+		return l, err
+	}
+	date, subject, _ := strings.Cut(out, "\x00")
+	if l.lastCommit, err = time.Parse(time.RFC3339, date); err != nil {
+		return l, fmt.Errorf("invalid commit date %s: %v", date, err)
+	}
+	l.sha = sha
+	l.subject = subject
+
+	revs := sha
+	if lastSeen != "" {
+		if lastSeen == sha {
+			return l, nil
+		}
+		if _, err := runGit(repoPath, "merge-base", "--is-ancestor", lastSeen, sha); err == nil {
+			revs = lastSeen + ".." + sha
+		} else {
+			l.rewritten = true
+		}
+	}
+	out, err = runGit(repoPath, "rev-list", "--count", revs)
+	if err != nil {
+		return l, err
+	}
+	if l.newCommits, err = strconv.Atoi(out); err != nil {
+		return l, fmt.Errorf("invalid commit count %s: %v", out, err)
+	}
+
+	return l, nil
+}
+
+// checkoutAt checks out the last commit on the default branch before the cutoff in
+// a detached state and returns its SHA. Commits are dated by their committer date.
+func checkoutAt(repoPath, defaultBranch string, cutoff time.Time) (string, error) {
+	sha, err := runGit(repoPath, "rev-list", "-1", "--first-parent", "--before="+cutoff.Format(time.RFC3339), defaultBranch)
+	if err != nil {
+		return "", err
+	}
+
+	sha = strings.TrimSpace(sha)
+	if sha == "" {
+		return "", fmt.Errorf("no commit on %s before %s", defaultBranch, cutoff.Format(time.RFC3339))
+	}
+
+	if _, err := runGit(repoPath, "checkout", "--detach", sha); err != nil {
+		return "", err
+	}
+
+	return sha, nil
+}
+
+// parseCutoff parses a timestamp in RFC 3339 format, or a date and time in the local
+// time zone
+func parseCutoff(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %s: use RFC 3339, e.g., 2025-03-01T23:59:00+01:00, or a local date and time, e.g., \"2025-03-01 23:59\"", s)
+}
+
+// selectAssignment looks up the assignment given by its slug or title, or prompts for
+// the assignment if no name is given, and returns its id
+func selectAssignment(client *api.RESTClient, classroomId int, name string, canPrompt bool) int {
+	var a ghapi.GitHubAssignment
+	var err error
+	if name != "" {
+		a, err = ghapi.FindAssignment(client, classroomId, name)
+	} else if canPrompt {
+		a, err = ghapi.PromptForAssignment(client, classroomId)
+	} else {
+		err = mmc.NoPromptError("--assignment <slug|title> or --assignment-id")
+	}
+	if err != nil {
+		mmc.Fatal(err)
+	}
+	return a.Id
+}
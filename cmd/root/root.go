@@ -2,21 +2,40 @@ package root
 
 import (
 	"github.com/cli/cli/v2/pkg/cmdutil"
+	"github.com/majikmate/gh-mmc/cmd/accounts"
 	"github.com/majikmate/gh-mmc/cmd/check"
 	"github.com/majikmate/gh-mmc/cmd/codespaces"
 	"github.com/majikmate/gh-mmc/cmd/initialize"
+	"github.com/majikmate/gh-mmc/cmd/members"
 	"github.com/majikmate/gh-mmc/cmd/pull"
+	"github.com/majikmate/gh-mmc/cmd/rosterdiff"
+	"github.com/majikmate/gh-mmc/cmd/students"
 	"github.com/majikmate/gh-mmc/cmd/sync"
+	"github.com/majikmate/gh-mmc/cmd/workspace"
 	"github.com/spf13/cobra"
 )
 
 func NewRootCmd(f *cmdutil.Factory) *cobra.Command {
+	var noPrompt bool
+
 	cmd := &cobra.Command{
 		Use:   "mmc <command>",
 		Short: "\nAn opinionated GitHub Classroom CLI",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			if noPrompt {
+				f.IOStreams.SetNeverPrompt(true)
+			}
+		},
 	}
 
+	cmd.PersistentFlags().BoolVar(&noPrompt, "no-prompt", false, "Fail instead of prompting, also set if stdin is not a terminal")
+
 	cmd.AddCommand(initialize.NewCmdInit(f))
+	cmd.AddCommand(workspace.NewCmdWorkspace(f))
+	cmd.AddCommand(accounts.NewCmdAccounts(f))
+	cmd.AddCommand(students.NewCmdStudents(f))
+	cmd.AddCommand(members.NewCmdMembers(f))
+	cmd.AddCommand(rosterdiff.NewCmdRosterDiff(f))
 	cmd.AddCommand(pull.NewCmdPull(f))
 	cmd.AddCommand(sync.NewCmdSync(f))
 	cmd.AddCommand(check.NewCmdCheck(f))
@@ -0,0 +1,249 @@
+package rosterdiff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/MakeNowJust/heredoc"
+	"github.com/cli/cli/v2/pkg/cmdutil"
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/majikmate/gh-mmc/pkg/ghapi"
+	"github.com/majikmate/gh-mmc/pkg/mmc"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdRosterDiff(f *cmdutil.Factory) *cobra.Command {
+	var assignmentName string
+	var links []string
+
+	cmd := &cobra.Command{
+		Use:   "roster-diff",
+		Short: "Compare the accepted assignments with the roster",
+		Long: heredoc.Doc(`
+
+			Lists the GitHub users who have accepted assignments but are not students of
+			the classroom, and the active students who have not accepted them yet.
+
+			When run inside an assignment folder, only that assignment is compared. An
+			assignment can also be given by its slug or title with --assignment.
+			Otherwise, all assignments of the classroom are compared.
+
+			Unknown GitHub users are often students who accepted with another GitHub
+			user than given in the roster. They can be linked to their roster entry
+			interactively, or with --link <github-user>=<student>. The link is saved in
+			the classroom metadata and kept when the classroom is initialized again, so
+			the repositories of the GitHub user are pulled to the folder of the student.`),
+		Example: heredoc.Doc(`
+			$ gh mmc roster-diff
+
+			# Compare a single assignment
+			$ gh mmc roster-diff --assignment html-basics
+
+			# Link the GitHub user jane-d to the student with the email jane.doe@school.at
+			$ gh mmc roster-diff --link jane-d=jane.doe@school.at`),
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := api.DefaultRESTClient()
+			if err != nil {
+				mmc.Fatal(fmt.Errorf("failed to create gh client: %v", err))
+			}
+
+			// Hold the lock while changing the classroom metadata
+			lock, err := mmc.LockClassroom()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			defer lock.Unlock() //nolint:errcheck
+
+			c, err := mmc.LoadClassroom()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			classroomFolder, err := mmc.FindClassroomFolder()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+
+			// Compare the assignment of the current folder, the given assignment or all
+			var assignments []ghapi.GitHubAssignment
+			if a, err := mmc.LoadAssignment(); err == nil && assignmentName == "" {
+				assignment, err := ghapi.GetAssignment(client, a.Id)
+				if err != nil {
+					mmc.Fatal(err)
+				}
+				assignments = append(assignments, assignment)
+			} else if err != nil && !errors.Is(err, mmc.ErrAssignmentNotFound) {
+				mmc.Fatal(err)
+			} else if assignmentName != "" {
+				assignment, err := ghapi.FindAssignment(client, c.Classroom.Id, assignmentName)
+				if err != nil {
+					mmc.Fatal(err)
+				}
+				assignments = append(assignments, assignment)
+			} else {
+				assignments, err = ghapi.ListAllAssignments(client, c.Classroom.Id)
+				if err != nil {
+					mmc.Fatal(fmt.Errorf("failed to get classroom assignments: %v", err))
+				}
+			}
+
+			type unknownSubmitter struct {
+				mmc.Submitter
+				assignments []string
+			}
+			type missingStudent struct {
+				name        string
+				githubUser  string
+				assignments []string
+			}
+			var unknown []*unknownSubmitter
+			var missing []*missingStudent
+			unknownByLogin := map[string]*unknownSubmitter{}
+			missingByUser := map[string]*missingStudent{}
+
+			classroomChanged := false
+			for _, assignment := range assignments {
+				acceptedAssignmentList, err := ghapi.ListAllAcceptedAssignments(client, assignment.Id, 15)
+				if err != nil {
+					fmt.Printf("Warning: failed to get accepted assignments for assignment %s: %v\n", assignment.Title, err)
+					continue
+				}
+
+				var submitters []mmc.Submitter
+				for _, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
+					for _, s := range acceptedAssignment.Students {
+						renamedFrom, changed := c.UpdateGithubUser(s.Id, s.Login)
+						if renamedFrom != "" {
+							fmt.Printf("GitHub user %s has been renamed to %s\n", renamedFrom, s.Login)
+						}
+						classroomChanged = classroomChanged || changed
+						submitters = append(submitters, mmc.Submitter{Id: s.Id, Login: s.Login})
+					}
+				}
+
+				r := c.CompareSubmitters(submitters)
+				for _, sub := range r.Unknown {
+					key := strings.ToLower(sub.Login)
+					u, ok := unknownByLogin[key]
+					if !ok {
+						u = &unknownSubmitter{Submitter: sub}
+						unknownByLogin[key] = u
+						unknown = append(unknown, u)
+					}
+					u.assignments = append(u.assignments, assignment.Slug)
+				}
+				for _, s := range r.NotAccepted {
+					key := strings.ToLower(s.GithubUser)
+					m, ok := missingByUser[key]
+					if !ok {
+						m = &missingStudent{name: s.Name, githubUser: s.GithubUser}
+						missingByUser[key] = m
+						missing = append(missing, m)
+					}
+					m.assignments = append(m.assignments, assignment.Slug)
+				}
+			}
+
+			if classroomChanged {
+				if err := c.Save(classroomFolder); err != nil {
+					mmc.Fatal(fmt.Errorf("failed to save classroom: %v", err))
+				}
+			}
+
+			fmt.Printf("Compared %d assignments of classroom %s.\n", len(assignments), c.Classroom.Name)
+			if len(unknown) == 0 && len(missing) == 0 {
+				fmt.Println("\nAll submitters are students of the classroom, and all active students have accepted.")
+				return
+			}
+			if len(unknown) > 0 {
+				fmt.Printf("\n%d GitHub users accepted assignments but are not in the roster:\n", len(unknown))
+				for _, u := range unknown {
+					fmt.Printf("  ? %-30s %s\n", u.Login, strings.Join(u.assignments, ", "))
+				}
+			}
+			if len(missing) > 0 {
+				fmt.Printf("\n%d students have not accepted all assignments:\n", len(missing))
+				for _, m := range missing {
+					fmt.Printf("  - %-30s %-25s %s\n", m.name, m.githubUser, strings.Join(m.assignments, ", "))
+				}
+			}
+
+			// Collect the links given by flags, or ask for them
+			type link struct {
+				githubUser string
+				student    string
+			}
+			var pending []link
+			for _, l := range links {
+				githubUser, student, ok := strings.Cut(l, "=")
+				if !ok || githubUser == "" || student == "" {
+					mmc.Fatal(fmt.Errorf("invalid link %s: use --link <github-user>=<student>", l))
+				}
+				pending = append(pending, link{githubUser, student})
+			}
+			if len(links) == 0 && len(unknown) > 0 && f.IOStreams.CanPrompt() {
+				// Offer the students who have not accepted, as they most likely used another GitHub user
+				const skip = "Skip"
+				options := []string{skip}
+				candidates := map[string]string{}
+				for _, m := range missing {
+					option := fmt.Sprintf("%s (%s)", m.name, m.githubUser)
+					options = append(options, option)
+					candidates[option] = m.githubUser
+				}
+
+				fmt.Println()
+				for _, u := range unknown {
+					var answer string
+					err := survey.AskOne(&survey.Select{
+						Message: fmt.Sprintf("Link GitHub user %s to:", u.Login),
+						Options: options,
+					}, &answer)
+					if err != nil {
+						mmc.Fatal(fmt.Errorf("failed to select student: %v", err))
+					}
+					if answer != skip {
+						pending = append(pending, link{u.Login, candidates[answer]})
+					}
+				}
+			} else if len(unknown) > 0 && len(links) == 0 {
+				fmt.Println("\nLink unknown GitHub users to students with --link <github-user>=<student>.")
+			}
+
+			linked := 0
+			for _, l := range pending {
+				s, err := c.FindStudent(l.student)
+				if err != nil {
+					mmc.Fatal(err)
+				}
+
+				submitter, ok := unknownByLogin[strings.ToLower(l.githubUser)]
+				if !ok {
+					user, err := ghapi.GetUser(client, l.githubUser)
+					if err != nil {
+						mmc.Fatal(fmt.Errorf("failed to look up GitHub user %s: %v", l.githubUser, err))
+					}
+					submitter = &unknownSubmitter{Submitter: mmc.Submitter{Id: user.Id, Login: user.Login}}
+				}
+
+				if err := c.LinkGithubUser(s, submitter.Id, submitter.Login); err != nil {
+					mmc.Fatal(err)
+				}
+				fmt.Printf("Linked GitHub user %s to %s.\n", submitter.Login, s.Name)
+				linked++
+			}
+
+			if linked > 0 {
+				if err := c.Save(classroomFolder); err != nil {
+					mmc.Fatal(fmt.Errorf("failed to save classroom: %v", err))
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&assignmentName, "assignment", "", "slug or title of the assignment to compare (defaults to all assignments)")
+	cmd.Flags().StringArrayVar(&links, "link", nil, "link a GitHub user to a student given by GitHub user, email, folder name or name, as <github-user>=<student>")
+
+	return cmd
+}
@@ -0,0 +1,373 @@
+package students
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/cli/cli/v2/pkg/cmdutil"
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/majikmate/gh-mmc/pkg/ghapi"
+	"github.com/majikmate/gh-mmc/pkg/mmc"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdStudents(f *cmdutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "students",
+		Short: "List and manage the students of a classroom",
+		Long: heredoc.Doc(`
+
+			List, add, edit and remove the students stored in the classroom metadata
+			without editing the accounts file and running gh mmc init again.
+
+			Students are selected by their GitHub user, email, folder name or name.`),
+	}
+
+	cmd.AddCommand(NewCmdStudentsList(f))
+	cmd.AddCommand(NewCmdStudentsAdd(f))
+	cmd.AddCommand(NewCmdStudentsEdit(f))
+	cmd.AddCommand(NewCmdStudentsRemove(f))
+
+	return cmd
+}
+
+func NewCmdStudentsList(f *cmdutil.Factory) *cobra.Command {
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the students of the classroom",
+		Long: heredoc.Doc(`
+
+			Lists the students of the classroom with their folder name, status and the
+			number of assignments of the classroom they have accepted.`),
+		Example: heredoc.Doc(`
+			$ gh mmc students list
+
+			# Skip counting the accepted assignments on GitHub
+			$ gh mmc students list --offline`),
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := mmc.LoadClassroom()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+
+			// Count the accepted assignments of each student by GitHub user
+			accepted := map[string]int{}
+			if !offline {
+				client, err := api.DefaultRESTClient()
+				if err != nil {
+					mmc.Fatal(fmt.Errorf("failed to create gh client: %v", err))
+				}
+
+				assignments, err := ghapi.ListAllAssignments(client, c.Classroom.Id)
+				if err != nil {
+					mmc.Fatal(fmt.Errorf("failed to get classroom assignments: %v", err))
+				}
+
+				for _, assignment := range assignments {
+					acceptedAssignmentList, err := ghapi.ListAllAcceptedAssignments(client, assignment.Id, 15)
+					if err != nil {
+						fmt.Printf("Warning: failed to get accepted assignments for assignment %s: %v\n", assignment.Title, err)
+						continue
+					}
+					for _, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
+						for _, s := range acceptedAssignment.Students {
+							if found, err := c.FindGithubStudent(s.Id, s.Login); err == nil {
+								accepted[strings.ToLower(found.GithubUser)]++
+							}
+						}
+					}
+				}
+			}
+
+			type row struct {
+				folder   string
+				name     string
+				email    string
+				user     string
+				status   string
+				accepted string
+			}
+			rows := make([]row, 0, len(c.Students))
+			for _, s := range c.Students {
+				folder, err := c.StudentFolder(s)
+				if err != nil {
+					folder = "-"
+				}
+				count := "-"
+				if !offline {
+					count = fmt.Sprintf("%d", accepted[strings.ToLower(s.GithubUser)])
+				}
+				rows = append(rows, row{folder, s.Name, s.Email, s.GithubUser, s.StatusName(), count})
+			}
+			sort.Slice(rows, func(i, j int) bool {
+				return strings.ToLower(rows[i].folder) < strings.ToLower(rows[j].folder)
+			})
+
+			fmt.Printf("%-30s %-30s %-40s %-25s %-10s %s\n", "FOLDER", "NAME", "EMAIL", "GITHUB USER", "STATUS", "ACCEPTED")
+			for _, r := range rows {
+				fmt.Printf("%-30s %-30s %-40s %-25s %-10s %s\n", r.folder, r.name, r.email, r.user, r.status, r.accepted)
+			}
+			fmt.Printf("\nTotal students: %d\n", len(rows))
+		},
+	}
+
+	cmd.Flags().BoolVar(&offline, "offline", false, "skip counting the accepted assignments on GitHub")
+
+	return cmd
+}
+
+func NewCmdStudentsAdd(f *cmdutil.Factory) *cobra.Command {
+	var name, email, githubUser, status string
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add a student to the classroom",
+		Long: heredoc.Doc(`
+
+			Adds a student to the classroom metadata.
+
+			The student is validated before it is saved: name, email and GitHub user are
+			required, the email and GitHub user must not be used by another student, and
+			the GitHub user must exist on GitHub. The GitHub user id is stored so that
+			the student keeps mapping to their repositories when renaming the GitHub user.
+
+			Students added this way are not written to the accounts file. Add them to the
+			accounts file as well, otherwise gh mmc init marks them as dropped.`),
+		Example: heredoc.Doc(`
+			$ gh mmc students add --name "Jane Doe" --email jane.doe@school.edu --github-user janedoe`),
+		Run: func(cmd *cobra.Command, args []string) {
+			if status != "" && !mmc.IsValidStatus(status) {
+				mmc.Fatal(fmt.Errorf("invalid status %s (valid statuses: %s)", status, strings.Join(mmc.Statuses, ", ")))
+			}
+
+			// Hold the lock while changing the classroom metadata
+			lock, err := mmc.LockClassroom()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			defer lock.Unlock() //nolint:errcheck
+
+			c, err := mmc.LoadClassroom()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+
+			var user ghapi.GitHubUser
+			if !offline && mmc.IsValidGithubLogin(githubUser) {
+				user, err = lookupGithubUser(githubUser)
+				if err != nil {
+					mmc.Fatal(err)
+				}
+				githubUser = user.Login
+			}
+
+			s, err := c.AddStudent(name, email, githubUser, user.Id, status)
+			if err != nil {
+				mmc.Fatal(err)
+			}
+
+			classroomFolder, err := mmc.FindClassroomFolder()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			if err := c.Save(classroomFolder); err != nil {
+				mmc.Fatal(fmt.Errorf("failed to save classroom: %v", err))
+			}
+
+			folder, _ := c.StudentFolder(*s)
+			fmt.Printf("Added %s (%s) with folder %s.\n", s.Name, s.GithubUser, folder)
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "full name of the student")
+	cmd.Flags().StringVar(&email, "email", "", "email address of the student")
+	cmd.Flags().StringVar(&githubUser, "github-user", "", "GitHub username of the student")
+	cmd.Flags().StringVar(&status, "status", "", "status of the student: active, dropped or auditing (default active)")
+	cmd.Flags().BoolVar(&offline, "offline", false, "skip the lookup of the GitHub user")
+	_ = cmd.MarkFlagRequired("name")
+	_ = cmd.MarkFlagRequired("email")
+	_ = cmd.MarkFlagRequired("github-user")
+
+	return cmd
+}
+
+func NewCmdStudentsEdit(f *cmdutil.Factory) *cobra.Command {
+	var name, email, githubUser, status string
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "edit <student>",
+		Short: "Edit a student of the classroom",
+		Long: heredoc.Doc(`
+
+			Changes the name, email, GitHub user or status of a student in the classroom
+			metadata. Only the given fields are changed.
+
+			The student is selected by GitHub user, email, folder name or name. The
+			changed student is validated the same way as by gh mmc students add. The
+			folder name of the student does not change, so existing clones are kept.
+
+			Set the status to dropped or auditing to skip the student in pull, sync,
+			check and codespaces list.`),
+		Example: heredoc.Doc(`
+			# Fix a misspelled GitHub user
+			$ gh mmc students edit jane.doe@school.edu --github-user JaneDoe
+
+			# Mark a student as dropped
+			$ gh mmc students edit janedoe --status dropped`),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Hold the lock while changing the classroom metadata
+			lock, err := mmc.LockClassroom()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			defer lock.Unlock() //nolint:errcheck
+
+			c, err := mmc.LoadClassroom()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+
+			s, err := c.FindStudent(args[0])
+			if err != nil {
+				mmc.Fatal(err)
+			}
+
+			update := *s
+			if cmd.Flags().Changed("name") {
+				update.Name = name
+			}
+			if cmd.Flags().Changed("email") {
+				update.Email = email
+			}
+			if cmd.Flags().Changed("status") {
+				update.Status = status
+			}
+			if cmd.Flags().Changed("github-user") {
+				update.GithubUser = githubUser
+				if !strings.EqualFold(githubUser, s.GithubUser) {
+					update.GithubId = 0
+				}
+				if !offline && update.GithubId == 0 && mmc.IsValidGithubLogin(githubUser) {
+					user, err := lookupGithubUser(githubUser)
+					if err != nil {
+						mmc.Fatal(err)
+					}
+					update.GithubUser = user.Login
+					update.GithubId = user.Id
+				}
+			}
+
+			if update == *s {
+				fmt.Println("Nothing to change.")
+				return
+			}
+
+			if err := c.UpdateStudent(s, update); err != nil {
+				mmc.Fatal(err)
+			}
+
+			classroomFolder, err := mmc.FindClassroomFolder()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			if err := c.Save(classroomFolder); err != nil {
+				mmc.Fatal(fmt.Errorf("failed to save classroom: %v", err))
+			}
+
+			fmt.Printf("Updated %s (%s).\n", s.Name, s.GithubUser)
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "full name of the student")
+	cmd.Flags().StringVar(&email, "email", "", "email address of the student")
+	cmd.Flags().StringVar(&githubUser, "github-user", "", "GitHub username of the student")
+	cmd.Flags().StringVar(&status, "status", "", "status of the student: active, dropped or auditing")
+	cmd.Flags().BoolVar(&offline, "offline", false, "skip the lookup of the GitHub user")
+
+	return cmd
+}
+
+func NewCmdStudentsRemove(f *cmdutil.Factory) *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "remove <student>",
+		Short: "Remove a student from the classroom",
+		Long: heredoc.Doc(`
+
+			Removes a student from the classroom metadata after confirmation. Local
+			folders of the student are not deleted.
+
+			To keep the student but skip them in other commands, set the status to
+			dropped with gh mmc students edit instead.`),
+		Example: heredoc.Doc(`
+			$ gh mmc students remove janedoe`),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Hold the lock while changing the classroom metadata
+			lock, err := mmc.LockClassroom()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			defer lock.Unlock() //nolint:errcheck
+
+			c, err := mmc.LoadClassroom()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+
+			s, err := c.FindStudent(args[0])
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			label := fmt.Sprintf("%s (%s)", s.Name, s.GithubUser)
+
+			if !yes && !mmc.Confirm(f.IOStreams.CanPrompt(), fmt.Sprintf("Remove %s from the classroom?", label)) {
+				fmt.Println("Removal cancelled.")
+				return
+			}
+
+			if err := c.RemoveStudent(s); err != nil {
+				mmc.Fatal(err)
+			}
+
+			classroomFolder, err := mmc.FindClassroomFolder()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			if err := c.Save(classroomFolder); err != nil {
+				mmc.Fatal(fmt.Errorf("failed to save classroom: %v", err))
+			}
+
+			fmt.Printf("Removed %s.\n", label)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "remove the student without confirmation")
+
+	return cmd
+}
+
+// lookupGithubUser looks up a GitHub user to verify it exists and get its id
+func lookupGithubUser(login string) (ghapi.GitHubUser, error) {
+	client, err := api.DefaultRESTClient()
+	if err != nil {
+		return ghapi.GitHubUser{}, fmt.Errorf("failed to create gh client: %v", err)
+	}
+
+	user, err := ghapi.GetUser(client, login)
+	if errors.Is(err, ghapi.ErrUserNotFound) {
+		return ghapi.GitHubUser{}, fmt.Errorf("GitHub user %s does not exist: use --offline to skip the lookup", login)
+	} else if err != nil {
+		return ghapi.GitHubUser{}, fmt.Errorf("failed to look up GitHub user %s: %v", login, err)
+	}
+	return user, nil
+}
@@ -17,7 +17,12 @@ import (
 
 func NewCmdSync(f *cmdutil.Factory) *cobra.Command {
 	var aId int
+	var assignmentName string
 	var verbose bool
+	var includeInactive bool
+	var allClassrooms bool
+	var students []string
+	var selectStudents bool
 
 	cmd := &cobra.Command{
 		Use:   "sync",
@@ -30,12 +35,38 @@ func NewCmdSync(f *cmdutil.Factory) *cobra.Command {
 			As a result, students can pull in updated code from the starter repo to their
 			local repositories. This is most useful when the starter repo is updated with, 
 			e.g., example code that shall be distributed to the students.
+
+			Repositories of dropped and auditing students are skipped, unless 
+			--include-inactive is set.
+
+			With --student, only the repositories of the given students are 
+			synchronized. Students are given by GitHub user, email, folder name or 
+			name, and can be selected interactively with --select-students.
+
+			GitHub users who accepted the assignment but are not in the roster, and 
+			students who have not accepted it yet, are reported at the end, see 
+			gh mmc roster-diff.
 			
 			The command can be run within the folder of an assignment, in which case the
 			assignment-id is automatically detected. If the assigment-id is known, it can 
-			be passed as an argument. Otherwise, the user will be prompted to 
-			select a classroom.`),
-		Example: `$ gh mmc sync`,
+			be passed as an argument, or the assignment can be selected by its slug or 
+			title with --assignment. Otherwise, the user will be prompted to select an 
+			assignment, or the command fails with --no-prompt or if stdin is not a 
+			terminal.
+
+			With --all-classrooms, the assignment folders of all classrooms below the 
+			workspace folder are synchronized, followed by a combined summary.`),
+		Example: heredoc.Doc(`
+			$ gh mmc sync
+
+			# Sync the repository of a single student
+			$ gh mmc sync --student janedoe
+
+			# Sync an assignment by its slug, e.g., in a cron job
+			$ gh mmc sync --assignment html-basics --no-prompt
+
+			# Sync all assignment folders of all classrooms of the workspace
+			$ gh mmc sync --all-classrooms`),
 		Run: func(cmd *cobra.Command, args []string) {
 			// Save the starting directory to return to it at the end
 			startingDir, err := os.Getwd()
@@ -51,88 +82,266 @@ func NewCmdSync(f *cmdutil.Factory) *cobra.Command {
 				mmc.Fatal(err)
 			}
 
-			c, err := mmc.LoadClassroom()
-			if err != nil {
-				mmc.Fatal(err)
+			opts := syncOptions{
+				aId:             aId,
+				assignmentName:  assignmentName,
+				canPrompt:       f.IOStreams.CanPrompt(),
+				verbose:         verbose,
+				includeInactive: includeInactive,
+				students:        students,
+				selectStudents:  selectStudents,
 			}
 
-			a, err := mmc.LoadAssignment()
-			if err != nil {
-				if errors.Is(err, mmc.ErrAssignmentNotFound) {
-					a, err := ghapi.PromptForAssignment(client, c.Classroom.Id)
-					if err != nil {
-						mmc.Fatal(err)
-					}
+			if selectStudents && !f.IOStreams.CanPrompt() {
+				mmc.Fatal(mmc.NoPromptError("--student"))
+			}
 
-					aId = a.Id
-				} else {
-					mmc.Fatal(err)
+			if allClassrooms {
+				if len(students) > 0 || selectStudents {
+					mmc.Fatal(fmt.Errorf("--student and --select-students cannot be used with --all-classrooms"))
 				}
-			} else {
-				aId = a.Id
+				if aId != 0 || assignmentName != "" {
+					mmc.Fatal(fmt.Errorf("--assignment-id and --assignment cannot be used with --all-classrooms"))
+				}
+				syncAllClassrooms(client, opts)
+				return
 			}
 
-			acceptedAssignmentList, err := ghapi.ListAllAcceptedAssignments(client, aId, 15)
+			syncAssignment(client, opts)
+		},
+	}
+
+	cmd.Flags().IntVarP(&aId, "assignment-id", "a", 0, "ID of the assignment")
+	cmd.Flags().StringVar(&assignmentName, "assignment", "", "slug or title of the assignment, instead of its ID")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose error output")
+	cmd.Flags().BoolVar(&includeInactive, "include-inactive", false, "include repositories of dropped and auditing students")
+	cmd.Flags().StringArrayVarP(&students, "student", "u", nil, "sync only the repositories of the student, given by GitHub user, email, folder name or name (repeatable)")
+	cmd.Flags().BoolVar(&selectStudents, "select-students", false, "select the students to sync the repositories of interactively")
+	cmd.Flags().BoolVar(&allClassrooms, "all-classrooms", false, "sync the assignment folders of all classrooms of the workspace")
+
+	return cmd
+}
+
+// syncOptions are the flags of the sync command
+type syncOptions struct {
+	aId             int
+	assignmentName  string
+	canPrompt       bool
+	verbose         bool
+	includeInactive bool
+	students        []string
+	selectStudents  bool
+}
+
+// syncResult summarizes the sync of an assignment
+type syncResult struct {
+	Classroom  string
+	Assignment string
+	Synced     int
+	Failed     int
+	Skipped    int
+}
+
+// syncAssignment synchronizes the student repositories of the assignment the current
+// directory belongs to, or of the assignment given by the options
+func syncAssignment(client *api.RESTClient, opts syncOptions) syncResult {
+	aId := opts.aId
+	verbose := opts.verbose
+	includeInactive := opts.includeInactive
+
+	// Hold the lock while changing the classroom metadata
+	lock, err := mmc.LockClassroom()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+	defer lock.Unlock() //nolint:errcheck
+
+	c, err := mmc.LoadClassroom()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	// The slug of the assignment, for the summary
+	var slug string
+
+	a, err := mmc.LoadAssignment()
+	if err != nil {
+		if !errors.Is(err, mmc.ErrAssignmentNotFound) {
+			mmc.Fatal(err)
+		}
+
+		if aId == 0 {
+			var a ghapi.GitHubAssignment
+			if opts.assignmentName != "" {
+				a, err = ghapi.FindAssignment(client, c.Classroom.Id, opts.assignmentName)
+			} else if opts.canPrompt {
+				a, err = ghapi.PromptForAssignment(client, c.Classroom.Id)
+			} else {
+				err = mmc.NoPromptError("--assignment <slug|title> or --assignment-id")
+			}
 			if err != nil {
 				mmc.Fatal(err)
 			}
 
-			totalSyched := 0
-			syncErrors := []string{}
-			for _, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
-				repoName := acceptedAssignment.Repository.Name
-				if len(acceptedAssignment.Students) == 1 {
-					if name, err := c.GetRepoName(acceptedAssignment.Students[0].Login); err == nil {
-						repoName = name
-					}
-				}
-				_, _, err := gh.Exec("repo", "sync", acceptedAssignment.Repository.FullName)
-				if err != nil {
-					//Don't bail on an error the repo could have changes preventing
-					//a pull, continue with rest of repos
-					errMsg := fmt.Sprintf("Failed to sync %s (%s): %v", repoName, acceptedAssignment.Repository.HtmlUrl, err)
-					syncErrors = append(syncErrors, errMsg)
-					if verbose {
-						fmt.Println(errMsg)
+			aId = a.Id
+			slug = a.Slug
+		}
+	} else {
+		aId = a.Id
+		slug = a.Name
+	}
+	if slug == "" {
+		assignment, err := ghapi.GetAssignment(client, aId)
+		if err != nil {
+			mmc.Fatal(err)
+		}
+		slug = assignment.Slug
+	}
+
+	acceptedAssignmentList, err := ghapi.ListAllAcceptedAssignments(client, aId, 15)
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	// Store the GitHub user ids and follow renamed GitHub users of the students
+	classroomChanged := false
+	var submitters []mmc.Submitter
+	for _, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
+		for _, s := range acceptedAssignment.Students {
+			renamedFrom, changed := c.UpdateGithubUser(s.Id, s.Login)
+			if renamedFrom != "" {
+				fmt.Printf("GitHub user %s has been renamed to %s\n", renamedFrom, s.Login)
+			}
+			classroomChanged = classroomChanged || changed
+			submitters = append(submitters, mmc.Submitter{Id: s.Id, Login: s.Login})
+		}
+	}
+	if classroomChanged {
+		classroomFolder, err := mmc.FindClassroomFolder()
+		if err != nil {
+			mmc.Fatal(err)
+		}
+		if err := c.Save(classroomFolder); err != nil {
+			mmc.Fatal(fmt.Errorf("failed to save classroom: %v", err))
+		}
+	}
+
+	// Limit the repositories to the selected students, if any
+	selected, err := c.FindStudents(opts.students)
+	if opts.selectStudents {
+		selected, err = c.PromptForStudents()
+	}
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	totalSyched := 0
+	skipped := 0
+	syncErrors := []string{}
+	for _, acceptedAssignment := range acceptedAssignmentList.AcceptedAssignments {
+		if selected != nil {
+			if !c.IsSubmittedBy(acceptedAssignment.Logins(), selected) {
+				continue
+			}
+		} else if !includeInactive && !c.IsActiveSubmitter(acceptedAssignment.Logins()) {
+			skipped++
+			continue
+		}
+		isGroup := acceptedAssignment.Assignment.IsGroup() || len(acceptedAssignment.Students) > 1
+		repoName := c.SubmissionFolder(acceptedAssignment.Repository.Name, acceptedAssignment.Assignment.Slug, isGroup, acceptedAssignment.Logins())
+		_, _, err := gh.Exec("repo", "sync", acceptedAssignment.Repository.FullName)
+		if err != nil {
+			//Don't bail on an error the repo could have changes preventing
+			//a pull, continue with rest of repos
+			errMsg := fmt.Sprintf("Failed to sync %s (%s): %v", repoName, acceptedAssignment.Repository.HtmlUrl, err)
+			syncErrors = append(syncErrors, errMsg)
+			if verbose {
+				fmt.Println(errMsg)
+			} else {
+				fmt.Printf("Failed to sync: %s (%s)\n", repoName, acceptedAssignment.Repository.HtmlUrl)
+			}
+			continue
+		}
+		fmt.Printf("Synchronized: %s (%s)\n", repoName, acceptedAssignment.Repository.HtmlUrl)
+		totalSyched++
+	}
+	if len(syncErrors) > 0 {
+		fmt.Printf("\n%d repositories failed to sync:\n", len(syncErrors))
+		if !verbose {
+			fmt.Println("Run with --verbose flag to see detailed error messages")
+			for _, errMsg := range syncErrors {
+				// Extract just the repo name from the error message for summary
+				prefix := "Failed to sync "
+				if len(errMsg) > len(prefix) && errMsg[:len(prefix)] == prefix {
+					remaining := errMsg[len(prefix):]
+					if parenIdx := strings.Index(remaining, " ("); parenIdx > 0 {
+						repoName := remaining[:parenIdx]
+						fmt.Printf("  - %s\n", repoName)
 					} else {
-						fmt.Printf("Failed to sync: %s (%s)\n", repoName, acceptedAssignment.Repository.HtmlUrl)
+						fmt.Printf("  - %s\n", remaining)
 					}
-					continue
 				}
-				fmt.Printf("Synchronized: %s (%s)\n", repoName, acceptedAssignment.Repository.HtmlUrl)
-				totalSyched++
 			}
-			if len(syncErrors) > 0 {
-				fmt.Printf("\n%d repositories failed to sync:\n", len(syncErrors))
-				if !verbose {
-					fmt.Println("Run with --verbose flag to see detailed error messages")
-					for _, errMsg := range syncErrors {
-						// Extract just the repo name from the error message for summary
-						prefix := "Failed to sync "
-						if len(errMsg) > len(prefix) && errMsg[:len(prefix)] == prefix {
-							remaining := errMsg[len(prefix):]
-							if parenIdx := strings.Index(remaining, " ("); parenIdx > 0 {
-								repoName := remaining[:parenIdx]
-								fmt.Printf("  - %s\n", repoName)
-							} else {
-								fmt.Printf("  - %s\n", remaining)
-							}
-						}
-					}
-				} else {
-					for _, errMsg := range syncErrors {
-						fmt.Printf("  %s\n", errMsg)
-					}
-				}
-				fmt.Printf("\nSuccessfully synced %d out of %d repositories.\n", totalSyched, totalSyched+len(syncErrors))
-			} else {
-				fmt.Printf("\nSuccessfully synced all %d repositories.\n", totalSyched)
+		} else {
+			for _, errMsg := range syncErrors {
+				fmt.Printf("  %s\n", errMsg)
 			}
-		},
+		}
+		fmt.Printf("\nSuccessfully synced %d out of %d repositories.\n", totalSyched, totalSyched+len(syncErrors))
+	} else {
+		fmt.Printf("\nSuccessfully synced all %d repositories.\n", totalSyched)
+	}
+	if skipped > 0 {
+		fmt.Printf("Skipped %d repositories of inactive students, use --include-inactive to include them.\n", skipped)
 	}
 
-	cmd.Flags().IntVarP(&aId, "assignment-id", "a", 0, "ID of the assignment")
-	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose error output")
+	// Report submitters not in the roster and students who have not accepted yet
+	report := c.CompareSubmitters(submitters)
+	if selected != nil {
+		report = report.ForStudents(selected)
+	}
+	report.Print()
 
-	return cmd
+	return syncResult{
+		Classroom:  c.Classroom.Name,
+		Assignment: slug,
+		Synced:     totalSyched,
+		Failed:     len(syncErrors),
+		Skipped:    skipped,
+	}
+}
+
+// syncAllClassrooms synchronizes the assignment folders of all classrooms of the workspace
+func syncAllClassrooms(client *api.RESTClient, opts syncOptions) {
+	classroomFolders, err := mmc.WorkspaceClassroomFolders()
+	if err != nil {
+		mmc.Fatal(err)
+	}
+
+	var results []syncResult
+	for _, classroomFolder := range classroomFolders {
+		assignmentFolders, err := mmc.ListAssignmentFolders(classroomFolder)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+
+		for _, assignmentFolder := range assignmentFolders {
+			fmt.Printf("\n=== %s ===\n\n", assignmentFolder)
+			if err := os.Chdir(assignmentFolder); err != nil {
+				mmc.Fatal(fmt.Errorf("failed to change to assignment directory: %v", err))
+			}
+			results = append(results, syncAssignment(client, opts))
+		}
+	}
+
+	var total syncResult
+	fmt.Printf("\n%-30s %-30s %8s %8s %8s\n", "CLASSROOM", "ASSIGNMENT", "SYNCED", "FAILED", "SKIPPED")
+	for _, r := range results {
+		fmt.Printf("%-30s %-30s %8d %8d %8d\n", r.Classroom, r.Assignment, r.Synced, r.Failed, r.Skipped)
+		total.Synced += r.Synced
+		total.Failed += r.Failed
+		total.Skipped += r.Skipped
+	}
+	fmt.Printf("%-30s %-30s %8d %8d %8d\n", "TOTAL", fmt.Sprintf("%d assignments", len(results)), total.Synced, total.Failed, total.Skipped)
 }
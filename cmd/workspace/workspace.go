@@ -0,0 +1,112 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/cli/cli/v2/pkg/cmdutil"
+	"github.com/majikmate/gh-mmc/pkg/mmc"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdWorkspace(f *cmdutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "workspace",
+		Short: "Manage a workspace of several classroom folders",
+		Long: heredoc.Doc(`
+
+			A workspace is a folder containing several classroom folders, e.g., one per
+			parallel class. Commands run with --all-classrooms anywhere below the
+			workspace folder work on every classroom folder of the workspace.`),
+	}
+
+	cmd.AddCommand(NewCmdWorkspaceInit(f))
+	cmd.AddCommand(NewCmdWorkspaceList(f))
+
+	return cmd
+}
+
+func NewCmdWorkspaceInit(f *cmdutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Mark the current folder as a workspace",
+		Long: heredoc.Doc(`
+
+			Marks the current folder as a workspace by creating .mmc/workspace.json.
+			Run it in the folder containing the classroom folders.`),
+		Example: `$ gh mmc workspace init`,
+		Run: func(cmd *cobra.Command, args []string) {
+			currentDir, err := os.Getwd()
+			if err != nil {
+				mmc.Fatal(fmt.Errorf("failed to get current directory: %v", err))
+			}
+
+			if err := mmc.InitWorkspace(currentDir); err != nil {
+				mmc.Fatal(err)
+			}
+
+			folders, err := mmc.ListClassroomFolders(currentDir)
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			fmt.Printf("Initialized workspace in %s with %d classroom folders.\n", currentDir, len(folders))
+		},
+	}
+
+	return cmd
+}
+
+func NewCmdWorkspaceList(f *cmdutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the classroom folders of the workspace",
+		Long: heredoc.Doc(`
+
+			Lists the classroom folders below the workspace folder with their
+			organization, classroom, number of students and assignment folders.`),
+		Example: `$ gh mmc workspace list`,
+		Run: func(cmd *cobra.Command, args []string) {
+			startingDir, err := os.Getwd()
+			if err != nil {
+				mmc.Fatal(fmt.Errorf("failed to get current directory: %v", err))
+			}
+			defer func() {
+				_ = os.Chdir(startingDir)
+			}()
+
+			workspaceFolder, err := mmc.FindWorkspaceFolder()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+			classroomFolders, err := mmc.WorkspaceClassroomFolders()
+			if err != nil {
+				mmc.Fatal(err)
+			}
+
+			fmt.Printf("%-30s %-25s %-30s %8s %11s\n", "FOLDER", "ORGANIZATION", "CLASSROOM", "STUDENTS", "ASSIGNMENTS")
+			for _, classroomFolder := range classroomFolders {
+				if err := os.Chdir(classroomFolder); err != nil {
+					mmc.Fatal(fmt.Errorf("failed to change to classroom directory: %v", err))
+				}
+				c, err := mmc.LoadClassroom()
+				if err != nil {
+					mmc.Fatal(err)
+				}
+				assignmentFolders, err := mmc.ListAssignmentFolders(classroomFolder)
+				if err != nil {
+					mmc.Fatal(err)
+				}
+
+				folder, err := filepath.Rel(workspaceFolder, classroomFolder)
+				if err != nil {
+					folder = classroomFolder
+				}
+				fmt.Printf("%-30s %-25s %-30s %8d %11d\n", folder, c.Organization.Login, c.Classroom.Name, len(c.Students), len(assignmentFolders))
+			}
+		},
+	}
+
+	return cmd
+}
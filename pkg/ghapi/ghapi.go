@@ -1,10 +1,12 @@
 package ghapi
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"math"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -42,6 +44,117 @@ func GetOrganization(client *api.RESTClient, orgName string) (GitHubOrganization
 	return response, nil
 }
 
+type GitHubUser struct {
+	Id      int    `json:"id"`
+	Login   string `json:"login"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	HtmlUrl string `json:"html_url"`
+}
+
+var (
+	ErrUserNotFound = errors.New("GitHub user not found")
+)
+
+// GetUser looks up a GitHub user by login, returning ErrUserNotFound if the user does not exist
+func GetUser(client *api.RESTClient, login string) (GitHubUser, error) {
+	var response GitHubUser
+
+	err := client.Get(fmt.Sprintf("users/%s", login), &response)
+	if err != nil {
+		var httpErr *api.HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+			return GitHubUser{}, fmt.Errorf("%w: %s", ErrUserNotFound, login)
+		}
+		return GitHubUser{}, err
+	}
+
+	return response, nil
+}
+
+// Roles of organization members
+const (
+	OrgRoleAll    = "all"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// ListAllOrgMembers returns the members of an organization with the role, one of
+// OrgRoleAll, OrgRoleAdmin or OrgRoleMember
+func ListAllOrgMembers(client *api.RESTClient, orgName string, role string) ([]GitHubUser, error) {
+	var allMembers []GitHubUser
+	perPage := 100
+
+	for page := 1; ; page++ {
+		var response []GitHubUser
+		err := client.Get(fmt.Sprintf("orgs/%s/members?role=%s&page=%v&per_page=%v", orgName, role, page, perPage), &response)
+		if err != nil {
+			return nil, err
+		}
+
+		allMembers = append(allMembers, response...)
+		if len(response) < perPage {
+			break
+		}
+	}
+
+	return allMembers, nil
+}
+
+type GitHubOrgInvitation struct {
+	Id        int    `json:"id"`
+	Login     string `json:"login"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ListAllOrgInvitations returns the pending invitations of an organization
+func ListAllOrgInvitations(client *api.RESTClient, orgName string) ([]GitHubOrgInvitation, error) {
+	var allInvitations []GitHubOrgInvitation
+	perPage := 100
+
+	for page := 1; ; page++ {
+		var response []GitHubOrgInvitation
+		err := client.Get(fmt.Sprintf("orgs/%s/invitations?page=%v&per_page=%v", orgName, page, perPage), &response)
+		if err != nil {
+			return nil, err
+		}
+
+		allInvitations = append(allInvitations, response...)
+		if len(response) < perPage {
+			break
+		}
+	}
+
+	return allInvitations, nil
+}
+
+// InviteOrgMember invites the GitHub user with the id to the organization as a member
+func InviteOrgMember(client *api.RESTClient, orgName string, userId int) (GitHubOrgInvitation, error) {
+	body, err := json.Marshal(map[string]any{
+		"invitee_id": userId,
+		"role":       "direct_member",
+	})
+	if err != nil {
+		return GitHubOrgInvitation{}, err
+	}
+
+	var response GitHubOrgInvitation
+	err = client.Post(fmt.Sprintf("orgs/%s/invitations", orgName), bytes.NewReader(body), &response)
+	if err != nil {
+		return GitHubOrgInvitation{}, err
+	}
+
+	return response, nil
+}
+
+// RemoveOrgMember removes the GitHub user from the organization, cancelling a pending
+// invitation as well
+func RemoveOrgMember(client *api.RESTClient, orgName string, login string) error {
+	return client.Delete(fmt.Sprintf("orgs/%s/memberships/%s", orgName, login), nil)
+}
+
 func GetClassroom(client *api.RESTClient, classroomID int) (GitHubClassroom, error) {
 	var response GitHubClassroom
 
@@ -105,6 +218,29 @@ func PromptForClassroom(client *api.RESTClient) (classroomId GitHubClassroom, er
 	return optionMap[answer.Classroom], nil
 }
 
+// FindClassroom looks up a classroom by its name, ignoring case
+func FindClassroom(client *api.RESTClient, name string) (GitHubClassroom, error) {
+	perPage := 100
+
+	for page := 1; ; page++ {
+		classrooms, err := ListClassrooms(client, page, perPage)
+		if err != nil {
+			return GitHubClassroom{}, err
+		}
+
+		for _, classroom := range classrooms {
+			if strings.EqualFold(classroom.Name, name) {
+				return classroom, nil
+			}
+		}
+		if len(classrooms) < perPage {
+			break
+		}
+	}
+
+	return GitHubClassroom{}, fmt.Errorf("classroom %s not found", name)
+}
+
 func ListOrganizations(client *api.RESTClient, page int, perPage int) ([]GitHubOrganization, error) {
 	var response []GitHubOrganization
 
@@ -328,6 +464,11 @@ type GitHubAssignment struct {
 	StarterCodeRepository       GithubRepository `json:"starter_code_repository"`
 }
 
+// IsGroup checks if the assignment is submitted by teams of students
+func (a GitHubAssignment) IsGroup() bool {
+	return a.AssignmentType == "group"
+}
+
 type GitHubAssignmentList struct {
 	Assignments     []GitHubAssignment
 	GitHubClassroom GitHubClassroom
@@ -428,6 +569,35 @@ func PromptForAssignment(client *api.RESTClient, classroomId int) (assignment Gi
 	return optionMap[answer.Assignment], nil
 }
 
+// FindAssignment looks up an assignment of a classroom by its slug or title, ignoring case
+func FindAssignment(client *api.RESTClient, classroomId int, query string) (GitHubAssignment, error) {
+	assignments, err := ListAllAssignments(client, classroomId)
+	if err != nil {
+		return GitHubAssignment{}, err
+	}
+
+	for _, assignment := range assignments {
+		if strings.EqualFold(assignment.Slug, query) {
+			return assignment, nil
+		}
+	}
+
+	var matches []GitHubAssignment
+	for _, assignment := range assignments {
+		if strings.EqualFold(assignment.Title, query) {
+			matches = append(matches, assignment)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return GitHubAssignment{}, fmt.Errorf("assignment %s not found in classroom", query)
+	case 1:
+		return matches[0], nil
+	default:
+		return GitHubAssignment{}, fmt.Errorf("assignment title %s is ambiguous: use the assignment slug", query)
+	}
+}
+
 func NewAssignmentList(assignments []GitHubAssignment) GitHubAssignmentList {
 	if len(assignments) == 0 {
 		return GitHubAssignmentList{
@@ -466,6 +636,15 @@ type GitHubAcceptedAssignment struct {
 	Assignment             GitHubAssignment `json:"assignment"`
 }
 
+// Logins returns the GitHub logins of the students of the accepted assignment
+func (a GitHubAcceptedAssignment) Logins() []string {
+	logins := make([]string, 0, len(a.Students))
+	for _, s := range a.Students {
+		logins = append(logins, s.Login)
+	}
+	return logins
+}
+
 type GitHubAcceptedAssignmentList struct {
 	AcceptedAssignments []GitHubAcceptedAssignment
 	GitHubClassroom     GitHubClassroom
@@ -510,10 +689,10 @@ func GetAssignmentList(client *api.RESTClient, assignmentID int, page int, perPa
 	return response, nil
 }
 
-func NumberOfAcceptedAssignmentsAndPages(client *api.RESTClient, assignmentID int, perPage int) (numPages, totalAccepted int) {
+func NumberOfAcceptedAssignmentsAndPages(client *api.RESTClient, assignmentID int, perPage int) (numPages, totalAccepted int, err error) {
 	assignment, err := GetAssignment(client, assignmentID)
 	if err != nil {
-		log.Fatal(err)
+		return 0, 0, err
 	}
 	numPages = int(math.Ceil(float64(assignment.Accepted) / float64(perPage)))
 	totalAccepted = assignment.Accepted
@@ -522,7 +701,10 @@ func NumberOfAcceptedAssignmentsAndPages(client *api.RESTClient, assignmentID in
 
 func ListAllAcceptedAssignments(client *api.RESTClient, assignmentID int, perPage int) (GitHubAcceptedAssignmentList, error) {
 
-	numPages, totalAccepted := NumberOfAcceptedAssignmentsAndPages(client, assignmentID, perPage)
+	numPages, totalAccepted, err := NumberOfAcceptedAssignmentsAndPages(client, assignmentID, perPage)
+	if err != nil {
+		return GitHubAcceptedAssignmentList{}, err
+	}
 
 	ch := make(chan assignmentList)
 	var wg sync.WaitGroup
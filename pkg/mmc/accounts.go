@@ -1,18 +1,21 @@
 package mmc
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"path/filepath"
-
-	"github.com/xuri/excelize/v2"
 )
 
 const (
-	// accountsFilePattern is the pattern to match the accounts file
-	accountsFilePattern = "?ccounts*.xlsx"
-	sheetName           = "Sheet1"
+	// accountsFilePattern is the pattern to match the accounts file, the format
+	// is picked by the file extension (see rosterReaders)
+	accountsFilePattern = "?ccounts*"
+	defaultSheetName    = "Sheet1"
 
 	// headers for the accounts file
 	nameHeader       = "Name"
@@ -20,6 +23,103 @@ const (
 	githubUserHeader = "GitHub User"
 )
 
+var (
+	ErrAccountsNotFound = errors.New("no accounts file found")
+)
+
+// RosterConfig configures how the accounts file is read. Empty values fall back
+// to the default sheet and to the default headers and their aliases.
+type RosterConfig struct {
+	Sheet            string
+	NameColumn       string
+	EmailColumn      string
+	GithubUserColumn string
+}
+
+// WithDefaults returns the configuration with empty values taken from defaults
+func (r RosterConfig) WithDefaults(defaults RosterConfig) RosterConfig {
+	if r.Sheet == "" {
+		r.Sheet = defaults.Sheet
+	}
+	if r.NameColumn == "" {
+		r.NameColumn = defaults.NameColumn
+	}
+	if r.EmailColumn == "" {
+		r.EmailColumn = defaults.EmailColumn
+	}
+	if r.GithubUserColumn == "" {
+		r.GithubUserColumn = defaults.GithubUserColumn
+	}
+	return r
+}
+
+// rosterColumn describes a column of the accounts file
+type rosterColumn struct {
+	header  string   // default header
+	aliases []string // alternative headers, e.g., from localized form exports
+	flag    string   // flag of `gh mmc init` to map the column
+}
+
+var (
+	nameColumn = rosterColumn{
+		header:  nameHeader,
+		aliases: []string{"Full Name", "Student Name", "Student", "Vollständiger Name", "Vor- und Nachname", "Nom", "Nombre"},
+		flag:    "--name-column",
+	}
+	emailColumn = rosterColumn{
+		header:  emailHeader,
+		aliases: []string{"E-Mail", "Email Address", "E-Mail-Adresse", "Mail", "Courriel", "Correo"},
+		flag:    "--email-column",
+	}
+	githubUserColumn = rosterColumn{
+		header:  githubUserHeader,
+		aliases: []string{"GitHub Username", "GitHub Login", "GitHub Account", "GitHub", "GitHub-Benutzername", "GitHub-Benutzer", "GitHub-Konto"},
+		flag:    "--github-user-column",
+	}
+)
+
+// normalizeHeader lowercases a header and drops everything but letters and digits,
+// so that "E-Mail", "e-mail" and "EMail" are treated alike
+func normalizeHeader(h string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(h) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// find returns the index of the column in the header row. A configured header
+// takes precedence over the default header and its aliases.
+func (c rosterColumn) find(header []string, configured string) (int, error) {
+	index := make(map[string]int)
+	for i := len(header) - 1; i >= 0; i-- {
+		index[normalizeHeader(header[i])] = i
+	}
+
+	found := make([]string, 0, len(header))
+	for _, h := range header {
+		if h = strings.TrimSpace(h); h != "" {
+			found = append(found, h)
+		}
+	}
+
+	if configured != "" {
+		if i, ok := index[normalizeHeader(configured)]; ok {
+			return i, nil
+		}
+		return 0, fmt.Errorf("column %s configured for %s not found (found headers: %s)", configured, c.header, strings.Join(found, ", "))
+	}
+
+	for _, h := range append([]string{c.header}, c.aliases...) {
+		if i, ok := index[normalizeHeader(h)]; ok {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no %s column found (found headers: %s): use %s to map it", c.header, strings.Join(found, ", "), c.flag)
+}
+
 type Accounts []student
 
 func (a *Accounts) GetRepoName(user string) (string, error) {
@@ -37,71 +137,320 @@ func getAccountFile() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if len(files) != 1 {
-		return "", fmt.Errorf("no accounts file found")
+
+	// only keep files with a supported roster format
+	accountFiles := make([]string, 0, len(files))
+	for _, f := range files {
+		if isRosterFile(f) {
+			accountFiles = append(accountFiles, f)
+		}
+	}
+
+	if len(accountFiles) == 0 {
+		return "", ErrAccountsNotFound
+	}
+	if len(accountFiles) > 1 {
+		return "", fmt.Errorf("multiple accounts files found: %s", strings.Join(accountFiles, ", "))
 	}
-	return files[0], nil
+	return accountFiles[0], nil
 }
 
 // ReadAccounts reads the accounts from the accounts file
-func ReadAccounts() ([]student, error) {
+func ReadAccounts(cfg RosterConfig) ([]student, error) {
 	// find the accounts file
 	accountFile, err := getAccountFile()
 	if err != nil {
-		return nil, fmt.Errorf("failed to find accounts file: %v", err)
+		return nil, fmt.Errorf("failed to find accounts file: %w", err)
 	}
 
-	// open the accounts file
-	file, err := excelize.OpenFile(accountFile)
+	// pick the reader by the file extension
+	reader, err := rosterReaderFor(accountFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open accounts file: %v", err)
+		return nil, err
 	}
-	defer file.Close() //nolint:errcheck
 
-	// get the rows from the sheet
-	rows, err := file.GetRows(sheetName)
+	// get the rows from the accounts file
+	rows, err := reader.ReadRows(accountFile, cfg.Sheet)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rows from sheet: %v", err)
+		return nil, err
 	}
 
-	// create a map to hold the accounts
-	accounts := make([]map[string]string, 0)
+	return parseAccounts(rows, cfg)
+}
 
-	// iterate over the rows, starting from the second row
-	for _, row := range rows[1:] {
-		// create a map to hold the account data
-		account := make(map[string]string)
-		for i, cell := range row {
-			account[rows[0][i]] = strings.TrimSpace(cell)
-		}
-		accounts = append(accounts, account)
+// parseAccounts validates the header row and converts the remaining rows into students
+func parseAccounts(rows [][]string, cfg RosterConfig) ([]student, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no header row found")
+	}
+
+	// check if there are the right headers
+	nameIdx, err := nameColumn.find(rows[0], cfg.NameColumn)
+	if err != nil {
+		return nil, err
+	}
+	emailIdx, err := emailColumn.find(rows[0], cfg.EmailColumn)
+	if err != nil {
+		return nil, err
+	}
+	githubUserIdx, err := githubUserColumn.find(rows[0], cfg.GithubUserColumn)
+	if err != nil {
+		return nil, err
 	}
 
 	// check if there are any accounts
-	if len(accounts) == 0 {
+	if len(rows) < 2 {
 		return nil, fmt.Errorf("no students found")
 	}
 
-	// check if therea are the right headers
-	if _, ok := accounts[0][nameHeader]; !ok {
-		return nil, fmt.Errorf("no Name column found")
-	}
-	if _, ok := accounts[0][emailHeader]; !ok {
-		return nil, fmt.Errorf("no Email column found")
-	}
-	if _, ok := accounts[0][githubUserHeader]; !ok {
-		return nil, fmt.Errorf("no GitHub User column found")
+	// cell returns the trimmed value of a column or an empty string for short rows
+	cell := func(row []string, i int) string {
+		if i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
 	}
 
-	// create a slice to hold the account structs
-	accountList := make([]student, 0)
-	for _, a := range accounts {
+	// create a slice to hold the account structs, starting from the second row
+	accountList := make([]student, 0, len(rows)-1)
+	for _, row := range rows[1:] {
 		accountList = append(accountList, student{
-			Name:       a[nameHeader],
-			Email:      a[emailHeader],
-			GithubUser: a[githubUserHeader],
+			Name:       cell(row, nameIdx),
+			Email:      cell(row, emailIdx),
+			GithubUser: cell(row, githubUserIdx),
 		})
 	}
 
 	return accountList, nil
 }
+
+// AccountIssue describes a problem with a row of the accounts file
+type AccountIssue struct {
+	Row     int
+	Account student
+	Message string
+}
+
+// studentEmailPattern matches the firstname.lastname@domain format RepoName relies on
+var studentEmailPattern = regexp.MustCompile(`^[^.@\s]+\.[^.@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// githubLoginPattern matches the characters allowed in GitHub usernames
+var githubLoginPattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9]|-[A-Za-z0-9]){0,38}$`)
+
+// AccountRow returns the row in the accounts file of the account at index i
+// as returned by ReadAccounts, the header being the first row
+func AccountRow(i int) int {
+	return i + 2
+}
+
+// IsValidGithubLogin checks if login is a syntactically valid GitHub username
+func IsValidGithubLogin(login string) bool {
+	return githubLoginPattern.MatchString(login)
+}
+
+// ValidateAccounts checks the accounts for empty rows, missing values, duplicate
+// GitHub users and emails, and emails not matching the firstname.lastname@domain
+// format. It does not check the accounts against GitHub.
+func ValidateAccounts(accounts []student) []AccountIssue {
+	issues := make([]AccountIssue, 0)
+	logins := make(map[string]int)
+	emails := make(map[string]int)
+
+	for i, a := range accounts {
+		row := AccountRow(i)
+		issue := func(format string, args ...any) {
+			issues = append(issues, AccountIssue{Row: row, Account: a, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if a.Name == "" && a.Email == "" && a.GithubUser == "" {
+			issue("empty row")
+			continue
+		}
+
+		if a.Name == "" {
+			issue("missing %s", nameHeader)
+		}
+
+		if a.Email == "" {
+			issue("missing %s", emailHeader)
+		} else {
+			if !studentEmailPattern.MatchString(a.Email) {
+				issue("email %s does not match the format firstname.lastname@domain", a.Email)
+			}
+			key := strings.ToLower(a.Email)
+			if first, ok := emails[key]; ok {
+				issue("duplicate email %s (also in row %d)", a.Email, first)
+			} else {
+				emails[key] = row
+			}
+		}
+
+		if a.GithubUser == "" {
+			issue("missing %s", githubUserHeader)
+		} else {
+			if !IsValidGithubLogin(a.GithubUser) {
+				issue("GitHub user %s is not a valid GitHub username", a.GithubUser)
+			}
+			// GitHub usernames are case insensitive
+			key := strings.ToLower(a.GithubUser)
+			if first, ok := logins[key]; ok {
+				issue("duplicate GitHub user %s (also in row %d)", a.GithubUser, first)
+			} else {
+				logins[key] = row
+			}
+		}
+	}
+
+	return issues
+}
+
+// headers of the roster export of GitHub Classroom
+const (
+	classroomRosterIdentifierHeader = "identifier"
+	classroomRosterGithubUserHeader = "github_username"
+	classroomRosterGithubIdHeader   = "github_id"
+	classroomRosterNameHeader       = "name"
+)
+
+// ClassroomRosterEntry is a student of the roster export of GitHub Classroom. The
+// GitHub user is empty if the student has not been linked to a GitHub account yet.
+type ClassroomRosterEntry struct {
+	Identifier string
+	GithubUser string
+	GithubId   int
+	Name       string
+}
+
+// ReadClassroomRoster reads the roster CSV file exported from GitHub Classroom
+func ReadClassroomRoster(path string) ([]ClassroomRosterEntry, error) {
+	rows, err := delimitedReader{}.ReadRows(path, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no header row found")
+	}
+
+	header := make(map[string]int)
+	for i, h := range rows[0] {
+		header[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, h := range []string{classroomRosterIdentifierHeader, classroomRosterGithubUserHeader} {
+		if _, ok := header[h]; !ok {
+			return nil, fmt.Errorf("no %s column found: %s is not a GitHub Classroom roster export", h, path)
+		}
+	}
+
+	// cell returns the trimmed value of a column or an empty string for missing columns
+	cell := func(row []string, h string) string {
+		if i, ok := header[h]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	entries := make([]ClassroomRosterEntry, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		e := ClassroomRosterEntry{
+			Identifier: cell(row, classroomRosterIdentifierHeader),
+			GithubUser: cell(row, classroomRosterGithubUserHeader),
+			Name:       cell(row, classroomRosterNameHeader),
+		}
+		if id := cell(row, classroomRosterGithubIdHeader); id != "" {
+			e.GithubId, err = strconv.Atoi(id)
+			if err != nil {
+				return nil, fmt.Errorf("invalid github_id %s of %s", id, e.Identifier)
+			}
+		}
+		if e.Identifier == "" && e.GithubUser == "" {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no students found")
+	}
+
+	return entries, nil
+}
+
+// ClassroomRosterMerge is the result of merging the roster export of GitHub Classroom
+// with the accounts file
+type ClassroomRosterMerge struct {
+	Students []student
+
+	// entries of the roster export not found in the accounts file
+	UnmatchedEntries []ClassroomRosterEntry
+
+	// accounts not found in the roster export
+	UnmatchedAccounts []student
+}
+
+// MergeClassroomRoster merges the roster export of GitHub Classroom with the accounts
+// file. Entries are matched with accounts by identifier, which is compared with the
+// email and the name of the account, or by GitHub user. The GitHub user and id of
+// linked entries take precedence, the name and email of the accounts are kept.
+// Unmatched entries and accounts are included as students as well.
+func MergeClassroomRoster(entries []ClassroomRosterEntry, accounts []student) ClassroomRosterMerge {
+	var m ClassroomRosterMerge
+	matched := make([]bool, len(accounts))
+
+	find := func(e ClassroomRosterEntry) int {
+		for i, a := range accounts {
+			if matched[i] || e.Identifier == "" {
+				continue
+			}
+			if strings.EqualFold(a.Email, e.Identifier) || strings.EqualFold(a.Name, e.Identifier) {
+				return i
+			}
+		}
+		for i, a := range accounts {
+			if !matched[i] && e.GithubUser != "" && strings.EqualFold(a.GithubUser, e.GithubUser) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, e := range entries {
+		i := find(e)
+		if i < 0 {
+			m.UnmatchedEntries = append(m.UnmatchedEntries, e)
+
+			s := student{
+				Name:       e.Name,
+				GithubUser: e.GithubUser,
+				GithubId:   e.GithubId,
+			}
+			if s.Name == "" {
+				s.Name = e.Identifier
+			}
+			if strings.Contains(e.Identifier, "@") {
+				s.Email = e.Identifier
+			}
+			m.Students = append(m.Students, s)
+			continue
+		}
+		matched[i] = true
+
+		s := accounts[i]
+		if e.GithubUser != "" {
+			s.GithubUser = e.GithubUser
+			s.GithubId = e.GithubId
+		}
+		if s.Name == "" {
+			s.Name = e.Name
+		}
+		m.Students = append(m.Students, s)
+	}
+
+	for i, a := range accounts {
+		if !matched[i] {
+			m.UnmatchedAccounts = append(m.UnmatchedAccounts, a)
+			m.Students = append(m.Students, a)
+		}
+	}
+
+	return m
+}
@@ -7,11 +7,42 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
+// team is a group of students submitting an accepted assignment together
+type team struct {
+	Name    string
+	Folder  string
+	Members []string
+}
+
+// snapshot records the commit a submission has been checked out at, i.e., the last
+// commit on the default branch before the cutoff, e.g., the deadline
+type snapshot struct {
+	Folder     string
+	Repository string
+	Cutoff     time.Time
+	Sha        string
+}
+
+// seen records the tip of the default branch of a repository at the last pull, to tell
+// the new commits of the next pull
+type seen struct {
+	Folder     string
+	Repository string
+	Sha        string
+	PulledAt   time.Time
+}
+
 type assignment struct {
-	Id   int
-	Name string
+	SchemaVersion int
+	Id            int
+	Name          string
+	Teams         []team
+	Snapshots     []snapshot
+	LastSeen      []seen
 }
 
 var (
@@ -33,7 +64,7 @@ func IsAssignmentFolder() (bool, error) {
 }
 
 func NewAssignment() *assignment {
-	return &assignment{}
+	return &assignment{SchemaVersion: assignmentSchemaVersion}
 }
 
 // FindAssignmentFolder searches upwards from the current directory to find the assignment folder root
@@ -77,12 +108,33 @@ func LoadAssignment() (*assignment, error) {
 		return nil, fmt.Errorf("failed to read %s file: %v", p, err)
 	}
 
+	// Upgrade files written by older versions
+	j, migrated, err := migrate(p, j, assignmentSchemaVersion, assignmentMigrations)
+	if err != nil {
+		return nil, err
+	}
+
 	a := NewAssignment()
 	err = json.Unmarshal(j, &a)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal %s file: %v", p, err)
 	}
 
+	// Save the upgraded file holding the lock of the classroom, or leave it to the
+	// holder of the lock
+	if migrated {
+		lockFolder, err := FindClassroomFolder()
+		if err != nil {
+			lockFolder = assignmentFolder
+		}
+		err = saveLocked(lockFolder, func() error {
+			return a.Save(assignmentFolder)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return a, nil
 }
 
@@ -91,6 +143,142 @@ func (a *assignment) Set(id int, name string) {
 	a.Name = name
 }
 
+// SetTeam records the team with the given GitHub user logins as members that is
+// cloned to folder, replacing an earlier record of the same folder
+func (a *assignment) SetTeam(name, folder string, members []string) {
+	t := team{
+		Name:    name,
+		Folder:  folder,
+		Members: members,
+	}
+	for i := range a.Teams {
+		if a.Teams[i].Folder == folder {
+			a.Teams[i] = t
+			return
+		}
+	}
+	a.Teams = append(a.Teams, t)
+}
+
+// SetSnapshot records the commit the repository cloned to folder has been checked out
+// at for the cutoff, replacing an earlier record of the same folder
+func (a *assignment) SetSnapshot(folder, repository string, cutoff time.Time, sha string) {
+	ss := snapshot{
+		Folder:     folder,
+		Repository: repository,
+		Cutoff:     cutoff,
+		Sha:        sha,
+	}
+	for i := range a.Snapshots {
+		if a.Snapshots[i].Folder == folder {
+			a.Snapshots[i] = ss
+			return
+		}
+	}
+	a.Snapshots = append(a.Snapshots, ss)
+}
+
+// SetLastSeen records the tip of the default branch of the repository cloned to folder
+// at the pull, replacing an earlier record of the same folder
+func (a *assignment) SetLastSeen(folder, repository, sha string, pulledAt time.Time) {
+	ls := seen{
+		Folder:     folder,
+		Repository: repository,
+		Sha:        sha,
+		PulledAt:   pulledAt,
+	}
+	for i := range a.LastSeen {
+		if a.LastSeen[i].Folder == folder {
+			a.LastSeen[i] = ls
+			return
+		}
+	}
+	a.LastSeen = append(a.LastSeen, ls)
+}
+
+// LastSeenSha returns the tip of the default branch of the repository cloned to folder
+// at the last pull, or an empty string if unknown. A record of another repository
+// cloned to the same folder earlier is ignored.
+func (a *assignment) LastSeenSha(folder, repository string) string {
+	for _, ls := range a.LastSeen {
+		if ls.Folder == folder && strings.EqualFold(ls.Repository, repository) {
+			return ls.Sha
+		}
+	}
+	return ""
+}
+
+// Forget removes the records of the team, snapshot and last seen tip of folder, e.g.,
+// when the folder has been pruned
+func (a *assignment) Forget(folder string) {
+	teams := a.Teams[:0]
+	for _, t := range a.Teams {
+		if t.Folder != folder {
+			teams = append(teams, t)
+		}
+	}
+	a.Teams = teams
+
+	snapshots := a.Snapshots[:0]
+	for _, ss := range a.Snapshots {
+		if ss.Folder != folder {
+			snapshots = append(snapshots, ss)
+		}
+	}
+	a.Snapshots = snapshots
+
+	lastSeen := a.LastSeen[:0]
+	for _, ls := range a.LastSeen {
+		if ls.Folder != folder {
+			lastSeen = append(lastSeen, ls)
+		}
+	}
+	a.LastSeen = lastSeen
+}
+
+// ArchiveFolder moves folder below the assignment folder to .mmc/archive of the
+// assignment folder and returns the path it has been moved to. The time of archiving
+// is appended to the name if a folder of the same name has been archived before.
+func ArchiveFolder(assignmentFolder, folder string, archivedAt time.Time) (string, error) {
+	archive := filepath.Join(assignmentFolder, mmcFolder, archiveFolder)
+	if err := os.MkdirAll(archive, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s directory: %v", archive, err)
+	}
+
+	to := filepath.Join(archive, folder)
+	if _, err := os.Stat(to); err == nil {
+		to = filepath.Join(archive, folder+"-"+archivedAt.Format("20060102-150405"))
+	}
+	if err := os.Rename(filepath.Join(assignmentFolder, folder), to); err != nil {
+		return "", fmt.Errorf("failed to archive %s: %v", folder, err)
+	}
+
+	return to, nil
+}
+
+// ShareMembers checks if the teams cloned to the folders have members in common,
+// in which case they are treated as the same submitter
+func (a *assignment) ShareMembers(folder1, folder2 string) bool {
+	members := make(map[string]bool)
+	for _, t := range a.Teams {
+		if t.Folder == folder1 {
+			for _, m := range t.Members {
+				members[strings.ToLower(m)] = true
+			}
+		}
+	}
+	for _, t := range a.Teams {
+		if t.Folder == folder2 {
+			for _, m := range t.Members {
+				if members[strings.ToLower(m)] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 func (a *assignment) Save(path string) error {
 	var err error
 	if path == "" {
@@ -113,22 +301,11 @@ func (a *assignment) Save(path string) error {
 		}
 	}
 
+	a.SchemaVersion = assignmentSchemaVersion
 	j, err := json.MarshalIndent(a, "", "    ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal classroom: %v", err)
-	}
-
-	p := filepath.Join(f, assigmentFile)
-	file, err := os.Create(p)
-	if err != nil {
-		return fmt.Errorf("failed to create %s file: %v", p, err)
-	}
-	defer file.Close() //nolint:errcheck
-
-	_, err = file.Write(j)
-	if err != nil {
-		return fmt.Errorf("failed to write %s file: %v", p, err)
+		return fmt.Errorf("failed to marshal assignment: %v", err)
 	}
 
-	return nil
+	return writeFileAtomic(filepath.Join(f, assigmentFile), j)
 }
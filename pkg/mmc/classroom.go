@@ -7,15 +7,69 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// student statuses, an empty status is treated as active. Dropped students have left
+// the classroom, auditing students attend without being graded. Commands skip the
+// submissions of dropped and auditing students by default.
+const (
+	StatusActive   = "active"
+	StatusDropped  = "dropped"
+	StatusAuditing = "auditing"
 )
 
+// Statuses lists the valid student statuses
+var Statuses = []string{StatusActive, StatusDropped, StatusAuditing}
+
+// IsValidStatus checks if status is a valid student status
+func IsValidStatus(status string) bool {
+	return slices.Contains(Statuses, status)
+}
+
 type student struct {
 	Name       string
 	Email      string
 	GithubUser string
+	GithubId   int
+	Status     string
+	Folder     string
+	// RosterGithubUser is the GitHub user given by the roster if the student has
+	// been linked to the GitHub user they accepted assignments with
+	RosterGithubUser string
+}
+
+// label returns the name of the student, or the email or GitHub user if the name is empty
+func (a *student) label() string {
+	switch {
+	case a.Name != "":
+		return a.Name
+	case a.Email != "":
+		return a.Email
+	default:
+		return a.GithubUser
+	}
+}
+
+// IsActive checks if the student is an active member of the classroom
+func (a *student) IsActive() bool {
+	return a.Status == "" || a.Status == StatusActive
+}
+
+// StatusName returns the status of the student, defaulting to active
+func (a *student) StatusName() string {
+	if a.Status == "" {
+		return StatusActive
+	}
+	return a.Status
 }
 
+// RepoName returns the legacy folder name lastname.firstname derived from an email
+// in the format firstname.lastname@domain, or the local part of other emails
 func (a *student) RepoName() string {
 	name := strings.Split(a.Email, "@")[0]
 	parts := strings.Split(name, ".")
@@ -37,9 +91,12 @@ type classroom struct {
 }
 
 type mmc struct {
-	Organization org
-	Classroom    classroom
-	Students     []student
+	SchemaVersion  int
+	Organization   org
+	Classroom      classroom
+	Roster         RosterConfig
+	FolderTemplate string
+	Students       []student
 }
 
 var (
@@ -61,7 +118,7 @@ func IsClassroomFolder() (bool, error) {
 }
 
 func NewClassroom() *mmc {
-	return &mmc{}
+	return &mmc{SchemaVersion: classroomSchemaVersion}
 }
 
 // FindClassroomFolder searches upwards from the current directory to find the classroom folder root
@@ -105,11 +162,28 @@ func LoadClassroom() (*mmc, error) {
 		return nil, fmt.Errorf("failed to read %s file: %v", p, err)
 	}
 
+	// Upgrade files written by older versions
+	data, migrated, err := migrate(p, data, classroomSchemaVersion, classroomMigrations)
+	if err != nil {
+		return nil, err
+	}
+
 	c := NewClassroom()
 	err = json.Unmarshal(data, &c)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal %s file: %v", p, err)
 	}
+	c.keepLegacyFolders(classroomFolder)
+
+	// Save the upgraded file holding the lock, or leave it to the holder of the lock
+	if migrated {
+		err := saveLocked(classroomFolder, func() error {
+			return c.Save(classroomFolder)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return c, nil
 }
@@ -128,24 +202,582 @@ func (c *mmc) SetClassroom(id int, name string) {
 	}
 }
 
-func (c *mmc) AddStudent(name, email, githubUser string) {
-	c.Students = append(c.Students, student{
+func (c *mmc) SetRoster(r RosterConfig) {
+	c.Roster = r
+}
+
+func (c *mmc) SetFolderTemplate(t string) {
+	c.FolderTemplate = t
+}
+
+// AddStudent validates and adds a student with the given status, or active if empty,
+// and returns it. The GitHub user id is 0 if it is unknown.
+func (c *mmc) AddStudent(name, email, githubUser string, githubId int, status string) (*student, error) {
+	if status == "" {
+		status = StatusActive
+	}
+	s := student{
 		Name:       name,
 		Email:      email,
 		GithubUser: githubUser,
+		GithubId:   githubId,
+		Status:     status,
+	}
+	if err := c.ValidateStudent(s, nil); err != nil {
+		return nil, err
+	}
+
+	c.Students = append(c.Students, s)
+	return &c.Students[len(c.Students)-1], nil
+}
+
+// UpdateStudent validates update and replaces the student s of the classroom with it
+func (c *mmc) UpdateStudent(s *student, update student) error {
+	if err := c.ValidateStudent(update, s); err != nil {
+		return err
+	}
+	*s = update
+	return nil
+}
+
+// RemoveStudent removes the student s from the classroom
+func (c *mmc) RemoveStudent(s *student) error {
+	for i := range c.Students {
+		if &c.Students[i] == s {
+			c.Students = slices.Delete(c.Students, i, i+1)
+			return nil
+		}
+	}
+	return fmt.Errorf("student %s not found", s.label())
+}
+
+// ValidateStudent checks that a new or updated student has a name, an email and a valid
+// GitHub user, a valid status and a folder name, and that the email, GitHub user and
+// GitHub user id are not used by another student. The student being updated is passed
+// as self, or nil.
+func (c *mmc) ValidateStudent(s student, self *student) error {
+	var problems []string
+
+	if strings.TrimSpace(s.Name) == "" {
+		problems = append(problems, "missing name")
+	}
+	if s.Email == "" {
+		problems = append(problems, "missing email")
+	} else if !strings.Contains(s.Email, "@") {
+		problems = append(problems, fmt.Sprintf("%s is not a valid email", s.Email))
+	}
+	if s.GithubUser == "" {
+		problems = append(problems, "missing GitHub user")
+	} else if !IsValidGithubLogin(s.GithubUser) {
+		problems = append(problems, fmt.Sprintf("%s is not a valid GitHub username", s.GithubUser))
+	}
+	if !IsValidStatus(s.StatusName()) {
+		problems = append(problems, fmt.Sprintf("invalid status %s (valid statuses: %s)", s.Status, strings.Join(Statuses, ", ")))
+	}
+	if s.Folder == "" && s.Name != "" {
+		if _, err := c.FolderName(s); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	for i := range c.Students {
+		other := &c.Students[i]
+		if other == self {
+			continue
+		}
+		if s.Email != "" && strings.EqualFold(other.Email, s.Email) {
+			problems = append(problems, fmt.Sprintf("email %s is already used by %s", s.Email, other.label()))
+		}
+		if s.GithubUser != "" && strings.EqualFold(other.GithubUser, s.GithubUser) {
+			problems = append(problems, fmt.Sprintf("GitHub user %s is already used by %s", s.GithubUser, other.label()))
+		}
+		if s.GithubId != 0 && other.GithubId == s.GithubId {
+			problems = append(problems, fmt.Sprintf("GitHub user id %d is already used by %s", s.GithubId, other.label()))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid student: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// FieldChange describes a changed field of a student
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// StudentChange describes a student whose roster entry has changed
+type StudentChange struct {
+	Student student
+	Fields  []FieldChange
+}
+
+// RosterDiff describes the changes of the students when merging a roster
+type RosterDiff struct {
+	Added       []student
+	Removed     []student
+	Reactivated []student
+	Changed     []StudentChange
+}
+
+// IsEmpty checks if the roster has not changed
+func (d RosterDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Reactivated) == 0 && len(d.Changed) == 0
+}
+
+// MergeStudents merges the accounts of a roster into the students of the classroom
+// and returns the changes. Students are matched by GitHub user id, then by GitHub
+// user, never matching a GitHub user owned by another GitHub user id, then by the
+// GitHub user of the roster a student has been linked from with roster-diff, and
+// finally by email. Matched students keep all other data, new students are added and
+// students missing from the roster are kept as dropped. Dropped students found in the
+// roster again are reactivated, auditing students keep their status. Students linked
+// to another GitHub user than given by the roster keep the linked GitHub user.
+func (c *mmc) MergeStudents(accounts []student) RosterDiff {
+	var diff RosterDiff
+	matched := make([]bool, len(c.Students))
+
+	for _, a := range accounts {
+		i := c.matchStudent(a, matched)
+		if i < 0 {
+			a.Status = StatusActive
+			c.Students = append(c.Students, a)
+			matched = append(matched, true)
+			diff.Added = append(diff.Added, a)
+			continue
+		}
+		matched[i] = true
+
+		s := &c.Students[i]
+
+		var fields []FieldChange
+		if s.Name != a.Name {
+			fields = append(fields, FieldChange{Field: nameHeader, Old: s.Name, New: a.Name})
+			s.Name = a.Name
+		}
+		if s.Email != a.Email {
+			fields = append(fields, FieldChange{Field: emailHeader, Old: s.Email, New: a.Email})
+			s.Email = a.Email
+		}
+		// students linked to another GitHub user keep the linked GitHub user
+		linked := s.RosterGithubUser != "" && strings.EqualFold(s.RosterGithubUser, a.GithubUser)
+		if !linked {
+			if s.GithubUser != a.GithubUser {
+				fields = append(fields, FieldChange{Field: githubUserHeader, Old: s.GithubUser, New: a.GithubUser})
+				s.GithubUser = a.GithubUser
+			}
+			if a.GithubId != 0 {
+				s.GithubId = a.GithubId
+			}
+			s.RosterGithubUser = ""
+		}
+		if len(fields) > 0 {
+			diff.Changed = append(diff.Changed, StudentChange{Student: *s, Fields: fields})
+		}
+
+		if s.Status == StatusDropped {
+			s.Status = StatusActive
+			diff.Reactivated = append(diff.Reactivated, *s)
+		}
+	}
+
+	for i := range c.Students {
+		s := &c.Students[i]
+		if !matched[i] && s.Status != StatusDropped {
+			s.Status = StatusDropped
+			diff.Removed = append(diff.Removed, *s)
+		}
+	}
+
+	return diff
+}
+
+// FindStudent looks up a student by GitHub user, email, folder name or name, ignoring case
+func (c *mmc) FindStudent(query string) (*student, error) {
+	for i, s := range c.Students {
+		if strings.EqualFold(s.GithubUser, query) || strings.EqualFold(s.Email, query) {
+			return &c.Students[i], nil
+		}
+	}
+	for i, s := range c.Students {
+		if folder, err := c.StudentFolder(s); err == nil && strings.EqualFold(folder, query) {
+			return &c.Students[i], nil
+		}
+	}
+
+	var found *student
+	for i, s := range c.Students {
+		if strings.EqualFold(s.Name, query) {
+			if found != nil {
+				return nil, fmt.Errorf("student %s is ambiguous: use the GitHub user or email", query)
+			}
+			found = &c.Students[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("student %s not found", query)
+	}
+	return found, nil
+}
+
+// FindStudents looks up the students given by GitHub user, email, folder name or name,
+// ignoring duplicates
+func (c *mmc) FindStudents(queries []string) ([]*student, error) {
+	var students []*student
+	for _, query := range queries {
+		s, err := c.FindStudent(query)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(students, s) {
+			students = append(students, s)
+		}
+	}
+	return students, nil
+}
+
+// PromptForStudents lets the user select students who have not been dropped, sorted
+// by folder name
+func (c *mmc) PromptForStudents() ([]*student, error) {
+	type option struct {
+		label   string
+		student *student
+	}
+	var options []option
+	for i := range c.Students {
+		s := &c.Students[i]
+		if s.Status == StatusDropped {
+			continue
+		}
+		folder, err := c.StudentFolder(*s)
+		if err != nil {
+			folder = "-"
+		}
+		options = append(options, option{fmt.Sprintf("%-30s %s (%s)", folder, s.Name, s.GithubUser), s})
+	}
+	if len(options) == 0 {
+		return nil, errors.New("no students found")
+	}
+	sort.Slice(options, func(i, j int) bool {
+		return strings.ToLower(options[i].label) < strings.ToLower(options[j].label)
 	})
+
+	labels := make([]string, len(options))
+	for i, o := range options {
+		labels[i] = o.label
+	}
+
+	var answer []int
+	err := survey.AskOne(&survey.MultiSelect{
+		Message:  "Select students:",
+		Options:  labels,
+		PageSize: 20,
+	}, &answer)
+	if err != nil {
+		return nil, err
+	}
+	if len(answer) == 0 {
+		return nil, errors.New("no students selected")
+	}
+
+	students := make([]*student, len(answer))
+	for i, index := range answer {
+		students[i] = options[index].student
+	}
+	return students, nil
+}
+
+// IsSubmittedBy checks if any of the GitHub users of an accepted assignment is one
+// of the students
+func (c *mmc) IsSubmittedBy(githubUsers []string, students []*student) bool {
+	for _, login := range githubUsers {
+		if s, err := c.FindGithubStudent(0, login); err == nil && slices.Contains(students, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchStudent returns the index of the student not matched yet an account of a roster
+// is merged into, or -1 if none, in the order described by MergeStudents
+func (c *mmc) matchStudent(a student, matched []bool) int {
+	for i, s := range c.Students {
+		if !matched[i] && a.GithubId != 0 && s.GithubId == a.GithubId {
+			return i
+		}
+	}
+	for i, s := range c.Students {
+		if !matched[i] && a.GithubUser != "" && strings.EqualFold(s.GithubUser, a.GithubUser) && sameGithubId(s, a) {
+			return i
+		}
+	}
+	for i, s := range c.Students {
+		if !matched[i] && a.GithubUser != "" && strings.EqualFold(s.RosterGithubUser, a.GithubUser) {
+			return i
+		}
+	}
+	for i, s := range c.Students {
+		if !matched[i] && a.Email != "" && strings.EqualFold(s.Email, a.Email) {
+			return i
+		}
+	}
+	return -1
+}
+
+// KnownGithubUser returns the stored GitHub user id and GitHub user of the student an
+// account of a roster is merged into, if the id is known. The GitHub user of the roster
+// may be outdated after a rename, or even be taken by another GitHub user since, so it
+// must not replace them. Students linked to another GitHub user are not returned, as
+// they keep the linked GitHub user anyway.
+func (c *mmc) KnownGithubUser(a student) (int, string, bool) {
+	i := c.matchStudent(a, make([]bool, len(c.Students)))
+	if i < 0 {
+		return 0, "", false
+	}
+	s := c.Students[i]
+	if s.GithubId == 0 || s.RosterGithubUser != "" {
+		return 0, "", false
+	}
+	return s.GithubId, s.GithubUser, true
+}
+
+// sameGithubId checks if the GitHub user ids of two students are equal or unknown
+func sameGithubId(a, b student) bool {
+	return a.GithubId == 0 || b.GithubId == 0 || a.GithubId == b.GithubId
+}
+
+// FindGithubStudent looks up a student by GitHub user id or, if the id is not stored,
+// by GitHub user, ignoring case. The id is immutable, so students whose GitHub user
+// has been renamed are still found, and a GitHub user taken over by another id is not.
+func (c *mmc) FindGithubStudent(githubId int, githubUser string) (*student, error) {
+	if githubId != 0 {
+		for i, s := range c.Students {
+			if s.GithubId == githubId {
+				return &c.Students[i], nil
+			}
+		}
+	}
+	for i, s := range c.Students {
+		if strings.EqualFold(s.GithubUser, githubUser) && (s.GithubId == 0 || githubId == 0) {
+			return &c.Students[i], nil
+		}
+	}
+	return nil, fmt.Errorf("GitHub user %s not found", githubUser)
+}
+
+// UpdateGithubUser stores the GitHub user id of the student with the GitHub user and
+// updates the GitHub user of the student with the id if it has been renamed. It returns
+// the previous GitHub user if it has been renamed, and if the student has changed.
+func (c *mmc) UpdateGithubUser(githubId int, githubUser string) (renamedFrom string, changed bool) {
+	s, err := c.FindGithubStudent(githubId, githubUser)
+	if err != nil || githubId == 0 {
+		return "", false
+	}
+	if s.GithubId == 0 {
+		s.GithubId = githubId
+		changed = true
+	}
+	if s.GithubUser != githubUser {
+		if !strings.EqualFold(s.GithubUser, githubUser) {
+			renamedFrom = s.GithubUser
+		}
+		s.GithubUser = githubUser
+		changed = true
+	}
+	return renamedFrom, changed
+}
+
+// LinkGithubUser links a student to the GitHub user with the id, e.g., if the student
+// has accepted assignments with another GitHub user than given by the roster. The
+// GitHub user of the roster is kept, so the link survives initializing again, and
+// the folder name of the student is kept as well.
+func (c *mmc) LinkGithubUser(s *student, githubId int, githubUser string) error {
+	if other, err := c.FindGithubStudent(githubId, githubUser); err == nil && other != s {
+		return fmt.Errorf("GitHub user %s already belongs to %s", githubUser, other.label())
+	}
+
+	c.ResolveFolders()
+	if s.RosterGithubUser == "" {
+		s.RosterGithubUser = s.GithubUser
+	}
+	if strings.EqualFold(s.RosterGithubUser, githubUser) {
+		s.RosterGithubUser = ""
+	}
+	s.GithubUser = githubUser
+	s.GithubId = githubId
+	return nil
+}
+
+// Submitter is a GitHub user who has accepted an assignment
+type Submitter struct {
+	Id    int
+	Login string
+}
+
+// SubmitterReport lists the submitters of an assignment that are not students of the
+// classroom, and the active students who have not accepted the assignment yet
+type SubmitterReport struct {
+	Unknown     []Submitter
+	NotAccepted []student
+}
+
+// IsEmpty checks if all submitters are students and all active students have accepted
+func (r SubmitterReport) IsEmpty() bool {
+	return len(r.Unknown) == 0 && len(r.NotAccepted) == 0
+}
+
+// Print prints the submitters that are not in the roster and the active students who
+// have not accepted the assignment yet
+func (r SubmitterReport) Print() {
+	if len(r.Unknown) > 0 {
+		fmt.Printf("\n%d GitHub users accepted the assignment but are not in the roster, link them with gh mmc roster-diff:\n", len(r.Unknown))
+		for _, s := range r.Unknown {
+			fmt.Printf("  ? %s\n", s.Login)
+		}
+	}
+	if len(r.NotAccepted) > 0 {
+		fmt.Printf("\n%d students have not accepted the assignment yet:\n", len(r.NotAccepted))
+		for _, s := range r.NotAccepted {
+			fmt.Printf("  - %-30s %s\n", s.Name, s.GithubUser)
+		}
+	}
+}
+
+// ForStudents limits the report to the students who have not accepted the assignment
+// of the given students
+func (r SubmitterReport) ForStudents(students []*student) SubmitterReport {
+	var filtered SubmitterReport
+	for _, s := range r.NotAccepted {
+		for _, selected := range students {
+			if s.GithubUser == selected.GithubUser && s.Email == selected.Email {
+				filtered.NotAccepted = append(filtered.NotAccepted, s)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// CompareSubmitters compares the submitters of an assignment with the students
+func (c *mmc) CompareSubmitters(submitters []Submitter) SubmitterReport {
+	var r SubmitterReport
+	accepted := make([]bool, len(c.Students))
+	seen := map[string]bool{}
+
+	for _, sub := range submitters {
+		s, err := c.FindGithubStudent(sub.Id, sub.Login)
+		if err == nil {
+			for i := range c.Students {
+				if &c.Students[i] == s {
+					accepted[i] = true
+				}
+			}
+			continue
+		}
+		if key := strings.ToLower(sub.Login); !seen[key] {
+			seen[key] = true
+			r.Unknown = append(r.Unknown, sub)
+		}
+	}
+
+	for i, s := range c.Students {
+		if !accepted[i] && s.IsActive() {
+			r.NotAccepted = append(r.NotAccepted, s)
+		}
+	}
+
+	return r
 }
 
 func (c *mmc) GetRepoName(githubUser string) (string, error) {
+	return c.GetFolderName(0, githubUser)
+}
+
+// GetFolderName returns the folder name of the student with the GitHub user id or,
+// if the id is unknown, with the GitHub user
+func (c *mmc) GetFolderName(githubId int, githubUser string) (string, error) {
+	s, err := c.FindGithubStudent(githubId, githubUser)
+	if err != nil {
+		return "", err
+	}
+	return c.StudentFolder(*s)
+}
+
+// IsActiveSubmitter checks if an accepted assignment of the GitHub users should be
+// processed, i.e., if any of them is an active student or not in the classroom
+func (c *mmc) IsActiveSubmitter(githubUsers []string) bool {
+	if len(githubUsers) == 0 {
+		return true
+	}
+	for _, login := range githubUsers {
+		s, err := c.FindGithubStudent(0, login)
+		if err != nil || s.IsActive() {
+			return true
+		}
+	}
+	return false
+}
+
+// InactiveFolders returns the folder names of the students that are not active
+func (c *mmc) InactiveFolders() map[string]bool {
+	folders := map[string]bool{}
 	for _, s := range c.Students {
-		if s.GithubUser == githubUser {
-			return s.RepoName(), nil
+		if s.IsActive() {
+			continue
+		}
+		if name, err := c.StudentFolder(s); err == nil {
+			folders[name] = true
 		}
 	}
-	return "", fmt.Errorf("GitHub user %s not found", githubUser)
+	return folders
 }
 
+// TeamName derives the team name from the repository name of a group assignment,
+// which GitHub Classroom names after the assignment slug and the team
+func TeamName(repoName, assignmentSlug string) string {
+	return strings.TrimPrefix(repoName, assignmentSlug+"-")
+}
+
+// SubmissionFolder returns the name of the folder an accepted assignment is cloned
+// to. Individual assignments are named after the student, group assignments after
+// the team or, if the team name cannot be derived, after the joined names of the
+// members. If no name can be determined, the repository name is used.
+func (c *mmc) SubmissionFolder(repoName, assignmentSlug string, isGroup bool, logins []string) string {
+	if !isGroup {
+		if len(logins) == 1 {
+			if name, err := c.GetRepoName(logins[0]); err == nil {
+				return name
+			}
+		}
+		return repoName
+	}
+
+	if name := TeamName(repoName, assignmentSlug); name != "" && name != repoName {
+		return name
+	}
+
+	names := make([]string, 0, len(logins))
+	for _, login := range logins {
+		name, err := c.GetRepoName(login)
+		if err != nil {
+			name = login
+		}
+		names = append(names, transliterate(name))
+	}
+	if len(names) == 0 {
+		return repoName
+	}
+	sort.Strings(names)
+	return strings.Join(names, "+")
+}
+
+// Save saves the classroom metadata, storing the folder names of new students first
 func (c *mmc) Save(path string) error {
+	c.ResolveFolders()
+
 	var err error
 	if path == "" {
 		path, err = os.Getwd()
@@ -166,22 +798,11 @@ func (c *mmc) Save(path string) error {
 		}
 	}
 
+	c.SchemaVersion = classroomSchemaVersion
 	j, err := json.MarshalIndent(c, "", "    ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal classroom: %v", err)
 	}
 
-	p := filepath.Join(f, classroomFile)
-	file, err := os.Create(p)
-	if err != nil {
-		return fmt.Errorf("failed to create %s file: %v", p, err)
-	}
-	defer file.Close() //nolint:errcheck
-
-	_, err = file.Write(j)
-	if err != nil {
-		return fmt.Errorf("failed to write %s file: %v", p, err)
-	}
-
-	return nil
+	return writeFileAtomic(filepath.Join(f, classroomFile), j)
 }
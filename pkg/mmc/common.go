@@ -1,8 +1,11 @@
 package mmc
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 const (
@@ -10,9 +13,70 @@ const (
 
 	classroomFile = "classroom.json"
 	assigmentFile = "assignment.json"
+
+	archiveFolder = "archive"
 )
 
+// ErrNoPrompt is returned instead of prompting when prompts are disabled with --no-prompt
+// or stdin is not a terminal
+var ErrNoPrompt = errors.New("cannot prompt in non-interactive mode")
+
+// NoPromptError returns an error naming the flag to pass instead of being prompted
+func NoPromptError(flag string) error {
+	return fmt.Errorf("%w: pass %s", ErrNoPrompt, flag)
+}
+
+// Confirm asks the user a yes/no question, defaulting to no. It fails asking for --yes
+// if prompts are disabled, so the confirmation is never skipped by reading no answer.
+func Confirm(canPrompt bool, question string) bool {
+	if !canPrompt {
+		Fatal(NoPromptError("--yes"))
+	}
+
+	fmt.Printf("%s (y/N): ", question)
+	var response string
+	_, err := fmt.Scanln(&response)
+	if err != nil {
+		fmt.Println()
+		return false
+	}
+
+	response = strings.ToLower(response)
+	return response == "y" || response == "yes"
+}
+
 func Fatal(v ...any) {
+	releaseLocks()
 	fmt.Fprintln(os.Stderr, v...)
 	os.Exit(1)
 }
+
+// writeFileAtomic writes data to a temporary file next to p and renames it to p, so
+// p is never left truncated if writing is interrupted
+func writeFileAtomic(p string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %v", p, err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint:errcheck
+		return fmt.Errorf("failed to write %s file: %v", p, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close() //nolint:errcheck
+		return fmt.Errorf("failed to write %s file: %v", p, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s file: %v", p, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s file: %v", p, err)
+	}
+
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("failed to replace %s file: %v", p, err)
+	}
+	return nil
+}
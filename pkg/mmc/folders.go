@@ -0,0 +1,393 @@
+package mmc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultFolderTemplate names student folders lastname.firstname
+const DefaultFolderTemplate = "{{.LastName}}.{{.FirstName}}"
+
+// folderData is the data available in folder templates
+type folderData struct {
+	Name       string
+	FirstName  string
+	LastName   string
+	Email      string
+	EmailLocal string
+	GithubUser string
+}
+
+// folderFuncs are the functions available in folder templates
+var folderFuncs = template.FuncMap{
+	"slug":  slug,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// ParseFolderTemplate parses a folder template such as {{.LastName}}.{{.FirstName}},
+// {{.GithubUser}} or {{.Name | slug}}
+func ParseFolderTemplate(text string) (*template.Template, error) {
+	t, err := template.New("folder").Funcs(folderFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folder template %s: %v", text, err)
+	}
+
+	// execute with sample data to catch unknown fields
+	err = t.Execute(&bytes.Buffer{}, folderData{})
+	if err != nil {
+		return nil, fmt.Errorf("invalid folder template %s: %v", text, err)
+	}
+
+	return t, nil
+}
+
+// folderData returns the template data of the student. The first and last name are
+// taken from an email in the format firstname.lastname@domain, otherwise from the
+// first and last word of the name.
+func (a *student) folderData() folderData {
+	local := strings.Split(a.Email, "@")[0]
+	d := folderData{
+		Name:       a.Name,
+		Email:      a.Email,
+		EmailLocal: local,
+		GithubUser: a.GithubUser,
+	}
+
+	if parts := strings.Split(local, "."); len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+		d.FirstName = parts[0]
+		d.LastName = parts[1]
+	} else if words := strings.Fields(a.Name); len(words) > 0 {
+		d.FirstName = words[0]
+		d.LastName = words[len(words)-1]
+	}
+
+	return d
+}
+
+// folderName executes the folder template for a student
+func folderName(text string, s student) (string, error) {
+	t, err := ParseFolderTemplate(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, s.folderData()); err != nil {
+		return "", fmt.Errorf("failed to name folder of %s: %v", s.label(), err)
+	}
+
+	name := transliterate(b.String())
+	if !isValidFolderName(name) {
+		return "", fmt.Errorf("invalid folder name %q for %s: check the name and email of the student or the folder template", name, s.label())
+	}
+
+	return name, nil
+}
+
+// transliterations maps letters that are not transliterated by removing their accents
+var transliterations = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'Ä': "Ae", 'Ö': "Oe", 'Ü': "Ue",
+	'ß': "ss", 'ẞ': "SS", 'æ': "ae", 'Æ': "Ae", 'œ': "oe", 'Œ': "Oe",
+	'ø': "o", 'Ø': "O", 'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D",
+	'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "Th", 'ı': "i",
+}
+
+// transliterate makes a folder name portable across file systems and shells. Umlauts
+// and ligatures are transliterated, e.g., ä to ae and ß to ss, accents are removed,
+// e.g., é to e, and all characters but ASCII letters, digits, dots, dashes and
+// underscores, e.g., spaces and apostrophes, are dropped.
+func transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFC.String(s) {
+		if t, ok := transliterations[r]; ok {
+			b.WriteString(t)
+			continue
+		}
+		for _, d := range norm.NFD.String(string(r)) {
+			if d < unicode.MaxASCII && (unicode.IsLetter(d) || unicode.IsDigit(d) || strings.ContainsRune("._-", d)) {
+				b.WriteRune(d)
+			}
+		}
+	}
+	return b.String()
+}
+
+// isValidFolderName checks if name can be used as a folder name on all platforms
+func isValidFolderName(name string) bool {
+	if name == "" || strings.ContainsAny(name, `/\:*?"<>|`) {
+		return false
+	}
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") {
+		return false
+	}
+	return strings.IndexFunc(name, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+// slug lowercases s and replaces everything but letters and digits with dashes
+func slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// FolderName returns the name of the folder of a student. Classrooms initialized
+// without a folder template keep the legacy naming of RepoName, transliterated like
+// the names given by a folder template.
+func (c *mmc) FolderName(s student) (string, error) {
+	if c.FolderTemplate == "" {
+		name := transliterate(s.RepoName())
+		if !isValidFolderName(name) {
+			return "", fmt.Errorf("invalid folder name %q for %s: check the email of the student or set a folder template", name, s.label())
+		}
+		return name, nil
+	}
+	return folderName(c.FolderTemplate, s)
+}
+
+// keepLegacyFolders keeps the legacy folder names of the students of a classroom
+// without a folder template, which are not transliterated, if the folder exists in an
+// assignment folder. Otherwise, the existing clones would be cloned again under the
+// transliterated name and the clones with the annotations of the teacher pruned.
+func (c *mmc) keepLegacyFolders(classroomFolder string) {
+	if c.FolderTemplate != "" {
+		return
+	}
+	assignmentFolders, err := ListAssignmentFolders(classroomFolder)
+	if err != nil {
+		return
+	}
+
+	for i := range c.Students {
+		s := &c.Students[i]
+		if s.Folder != "" {
+			continue
+		}
+		legacy := s.RepoName()
+		if name, err := c.FolderName(*s); err == nil && name == legacy {
+			continue
+		}
+		for _, assignmentFolder := range assignmentFolders {
+			if _, err := os.Stat(filepath.Join(assignmentFolder, legacy)); err == nil {
+				s.Folder = legacy
+				break
+			}
+		}
+	}
+}
+
+// StudentFolder returns the stored folder name of a student, or the folder name given
+// by the folder template if none is stored yet
+func (c *mmc) StudentFolder(s student) (string, error) {
+	if s.Folder != "" {
+		return s.Folder, nil
+	}
+	return c.FolderName(s)
+}
+
+// StudentFolders returns the folder names of all students in the order of the students,
+// with an empty name for students whose folder cannot be named
+func (c *mmc) StudentFolders() []string {
+	folders := make([]string, len(c.Students))
+	for i, s := range c.Students {
+		folders[i], _ = c.StudentFolder(s)
+	}
+	return folders
+}
+
+// FolderCollision describes students whose folder name given by the folder template
+// collides with each other or with the folder of another student
+type FolderCollision struct {
+	Folder   string
+	Students []student
+}
+
+// ResolveFolders stores the folder name of every student that has none yet and returns
+// the number of stored folder names. Folder names colliding with each other or with a
+// stored folder name, ignoring case, get a suffix with the GitHub user id of the student.
+// Stored folder names never change, so a student keeps the folder between runs even if
+// another student with the same name joins later. Students whose folder cannot be named
+// are left unresolved.
+func (c *mmc) ResolveFolders() (int, []FolderCollision) {
+	// the folder names given by the template of students with a stored folder are
+	// taken as well, so a student joining later is told apart from suffixed students
+	taken := map[string]bool{}
+	for _, s := range c.Students {
+		if s.Folder == "" {
+			continue
+		}
+		taken[strings.ToLower(s.Folder)] = true
+		if name, err := c.FolderName(s); err == nil {
+			taken[strings.ToLower(name)] = true
+		}
+	}
+
+	// group the unresolved students by folder name, keeping the order of the students
+	var keys []string
+	names := map[string]string{}
+	groups := map[string][]int{}
+	for i, s := range c.Students {
+		if s.Folder != "" {
+			continue
+		}
+		name, err := c.FolderName(s)
+		if err != nil {
+			continue
+		}
+		key := strings.ToLower(name)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+			names[key] = name
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	resolved := 0
+	var collisions []FolderCollision
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) == 1 && !taken[key] {
+			c.Students[group[0]].Folder = names[key]
+			taken[key] = true
+			resolved++
+			continue
+		}
+
+		collision := FolderCollision{Folder: names[key]}
+		for _, i := range group {
+			s := &c.Students[i]
+			name := names[key]
+			if suffix := s.folderSuffix(); suffix != "" {
+				name += "-" + suffix
+			}
+			s.Folder = uniqueFolder(name, taken)
+			taken[strings.ToLower(s.Folder)] = true
+			resolved++
+			collision.Students = append(collision.Students, *s)
+		}
+		collisions = append(collisions, collision)
+	}
+
+	return resolved, collisions
+}
+
+// ResetFolders removes the stored folder names, e.g., when the folder template changes
+func (c *mmc) ResetFolders() {
+	for i := range c.Students {
+		c.Students[i].Folder = ""
+	}
+}
+
+// folderSuffix returns the suffix distinguishing colliding folder names, the GitHub
+// user id or, if unknown, the GitHub user
+func (a *student) folderSuffix() string {
+	if a.GithubId != 0 {
+		return strconv.Itoa(a.GithubId)
+	}
+	return slug(a.GithubUser)
+}
+
+// uniqueFolder returns name, or name with a counter if name is already taken
+func uniqueFolder(name string, taken map[string]bool) string {
+	unique := name
+	for n := 2; taken[strings.ToLower(unique)]; n++ {
+		unique = fmt.Sprintf("%s-%d", name, n)
+	}
+	return unique
+}
+
+// FolderRename describes a student folder to be renamed in an assignment folder
+type FolderRename struct {
+	From string
+	To   string
+}
+
+// PlanFolderRenames finds the student folders in the assignment folders below the
+// classroom folder that need to be renamed from the old folder names, given in the
+// order of the students as returned by StudentFolders, to the current folder names.
+// Folders whose new name is already taken are returned as conflicts.
+func (c *mmc) PlanFolderRenames(classroomFolder string, oldFolders []string) (renames []FolderRename, conflicts []FolderRename, err error) {
+	assignmentFolders, err := ListAssignmentFolders(classroomFolder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newFolders := c.StudentFolders()
+
+	for _, assignmentFolder := range assignmentFolders {
+		for i, from := range oldFolders {
+			to := newFolders[i]
+			if from == "" || to == "" || from == to {
+				continue
+			}
+
+			r := FolderRename{
+				From: filepath.Join(assignmentFolder, from),
+				To:   filepath.Join(assignmentFolder, to),
+			}
+			if _, err := os.Stat(r.From); err != nil {
+				continue
+			}
+			if _, err := os.Stat(r.To); err == nil {
+				conflicts = append(conflicts, r)
+				continue
+			}
+			renames = append(renames, r)
+		}
+	}
+
+	return renames, conflicts, nil
+}
+
+// ApplyFolderRenames renames the folders. If a rename fails, the folders renamed so far
+// are renamed back, so either all or none of the folders are renamed.
+func ApplyFolderRenames(renames []FolderRename) error {
+	for i, r := range renames {
+		if err := os.Rename(r.From, r.To); err != nil {
+			err = fmt.Errorf("failed to rename %s to %s: %v", r.From, r.To, err)
+			if rollbackErr := RevertFolderRenames(renames[:i]); rollbackErr != nil {
+				return fmt.Errorf("%v\n%v", err, rollbackErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// RevertFolderRenames renames applied folder renames back in reverse order, trying all
+// of them even if one fails
+func RevertFolderRenames(renames []FolderRename) error {
+	var failed []string
+	for i := len(renames) - 1; i >= 0; i-- {
+		r := renames[i]
+		if err := os.Rename(r.To, r.From); err != nil {
+			failed = append(failed, fmt.Sprintf("failed to rename %s back to %s: %v", r.To, r.From, err))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package mmc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const lockFile = "lock"
+
+var (
+	ErrLocked = errors.New("another gh mmc is running")
+)
+
+// Lock is an advisory lock on the metadata of a classroom, held by commands while
+// they change metadata files
+type Lock struct {
+	path string
+}
+
+var (
+	locksMu    sync.Mutex
+	heldLocks  []*Lock
+	handleOnce sync.Once
+)
+
+// LockClassroom locks the metadata of the classroom the current directory belongs to
+func LockClassroom() (*Lock, error) {
+	classroomFolder, err := FindClassroomFolder()
+	if err != nil {
+		return nil, err
+	}
+	return LockFolder(classroomFolder)
+}
+
+// LockFolder locks the metadata in the .mmc folder of folder, creating the .mmc folder
+// if needed. The lock is released by Unlock, or by Fatal and on interrupt. A lock left
+// behind by a process that no longer exists, e.g., after a panic, is taken over.
+func LockFolder(folder string) (*Lock, error) {
+	f := filepath.Join(folder, mmcFolder)
+	if err := os.MkdirAll(f, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s directory: %v", f, err)
+	}
+
+	p := filepath.Join(f, lockFile)
+	file, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		holder := ""
+		if data, err := os.ReadFile(p); err == nil {
+			holder = strings.TrimSpace(string(data))
+		}
+		if !removeStaleLock(p, holder) {
+			return nil, fmt.Errorf("%w (%s): wait for it to finish, or remove %s if no gh mmc is running", ErrLocked, holder, p)
+		}
+		file, err = os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("%w: wait for it to finish, or remove %s if no gh mmc is running", ErrLocked, p)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s file: %v", p, err)
+	}
+	_, err = fmt.Fprintf(file, "pid %d since %s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	file.Close() //nolint:errcheck
+	if err != nil {
+		os.Remove(p) //nolint:errcheck
+		return nil, fmt.Errorf("failed to write %s file: %v", p, err)
+	}
+
+	l := &Lock{path: p}
+	locksMu.Lock()
+	heldLocks = append(heldLocks, l)
+	locksMu.Unlock()
+
+	// release the locks when interrupted, e.g., by Ctrl-C
+	handleOnce.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-ch
+			releaseLocks()
+			os.Exit(130)
+		}()
+	})
+
+	return l, nil
+}
+
+// removeStaleLock removes the lock file p with the content holder if the process holding
+// it no longer exists, and reports whether it has been removed. The lock file is moved
+// aside first, and restored if another process has taken the lock over in the meantime.
+func removeStaleLock(p, holder string) bool {
+	var pid int
+	if _, err := fmt.Sscanf(holder, "pid %d", &pid); err != nil || pid <= 0 || processExists(pid) {
+		return false
+	}
+
+	stale := fmt.Sprintf("%s.%d.stale", p, os.Getpid())
+	if err := os.Rename(p, stale); err != nil {
+		return false
+	}
+	data, err := os.ReadFile(stale)
+	if err != nil || strings.TrimSpace(string(data)) != holder {
+		os.Rename(stale, p) //nolint:errcheck
+		return false
+	}
+	os.Remove(stale) //nolint:errcheck
+	return true
+}
+
+// processExists checks if a process with the pid is running. On Windows, finding the
+// process succeeds only if it is running.
+func processExists(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = proc.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
+
+// saveLocked runs save holding the lock of folder, unless the process holds it already.
+// If another process holds the lock, saving is left to it and save is not run.
+func saveLocked(folder string, save func() error) error {
+	p := filepath.Join(folder, mmcFolder, lockFile)
+	locksMu.Lock()
+	held := false
+	for _, l := range heldLocks {
+		if filepath.Clean(l.path) == filepath.Clean(p) {
+			held = true
+		}
+	}
+	locksMu.Unlock()
+	if held {
+		return save()
+	}
+
+	l, err := LockFolder(folder)
+	if errors.Is(err, ErrLocked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer l.Unlock() //nolint:errcheck
+
+	return save()
+}
+
+// Unlock releases the lock
+func (l *Lock) Unlock() error {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+
+	for i, held := range heldLocks {
+		if held == l {
+			heldLocks = append(heldLocks[:i], heldLocks[i+1:]...)
+			break
+		}
+	}
+
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s file: %v", l.path, err)
+	}
+	return nil
+}
+
+// releaseLocks releases all locks held by the process
+func releaseLocks() {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+
+	for _, l := range heldLocks {
+		os.Remove(l.path) //nolint:errcheck
+	}
+	heldLocks = nil
+}
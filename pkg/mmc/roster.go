@@ -0,0 +1,280 @@
+package mmc
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// rosterReader reads the raw rows of a roster file, the first row being the header.
+// Readers of spreadsheet formats read the given sheet, file formats without sheets
+// ignore it.
+type rosterReader interface {
+	ReadRows(path string, sheet string) ([][]string, error)
+}
+
+// rosterReaders maps the supported file extensions to their reader
+var rosterReaders = map[string]rosterReader{
+	".xlsx": xlsxReader{},
+	".csv":  delimitedReader{},
+	".tsv":  delimitedReader{comma: '\t'},
+	".ods":  odsReader{},
+}
+
+// rosterReaderFor returns the reader for the file extension of path
+func rosterReaderFor(path string) (rosterReader, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	r, ok := rosterReaders[ext]
+	if !ok {
+		return nil, fmt.Errorf("unsupported accounts file format %s", ext)
+	}
+	return r, nil
+}
+
+// isRosterFile checks if the file extension of path is supported
+func isRosterFile(path string) bool {
+	_, err := rosterReaderFor(path)
+	return err == nil
+}
+
+// xlsxReader reads Excel workbooks
+type xlsxReader struct{}
+
+func (xlsxReader) ReadRows(path string, sheet string) ([][]string, error) {
+	file, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open accounts file: %v", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	// use the default sheet if it exists, otherwise the first sheet
+	sheets := file.GetSheetList()
+	if sheet == "" {
+		sheet = defaultSheetName
+		if !slices.Contains(sheets, sheet) && len(sheets) > 0 {
+			sheet = sheets[0]
+		}
+	}
+	if !slices.Contains(sheets, sheet) {
+		return nil, fmt.Errorf("sheet %s not found (found sheets: %s)", sheet, strings.Join(sheets, ", "))
+	}
+
+	rows, err := file.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows from sheet: %v", err)
+	}
+
+	return rows, nil
+}
+
+// delimitedReader reads CSV and TSV files. If comma is not set, the delimiter
+// is detected from the header line, as spreadsheet applications in many locales
+// export CSV files separated by semicolons.
+type delimitedReader struct {
+	comma rune
+}
+
+func (d delimitedReader) ReadRows(path string, _ string) ([][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open accounts file: %v", err)
+	}
+
+	// strip the byte order mark written by Excel
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	comma := d.comma
+	if comma == 0 {
+		comma = detectDelimiter(data)
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse accounts file: %v", err)
+	}
+
+	return rows, nil
+}
+
+// detectDelimiter returns the most frequent delimiter in the first line of data
+func detectDelimiter(data []byte) rune {
+	line, _ := bufio.NewReader(bytes.NewReader(data)).ReadString('\n')
+
+	comma := ','
+	count := strings.Count(line, ",")
+	for _, c := range []rune{';', '\t'} {
+		if n := strings.Count(line, string(c)); n > count {
+			comma = c
+			count = n
+		}
+	}
+	return comma
+}
+
+// odsReader reads OpenDocument spreadsheets
+type odsReader struct{}
+
+func (odsReader) ReadRows(path string, sheet string) ([][]string, error) {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open accounts file: %v", err)
+	}
+	defer z.Close() //nolint:errcheck
+
+	for _, f := range z.File {
+		if f.Name != "content.xml" {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open content of accounts file: %v", err)
+		}
+		defer rc.Close() //nolint:errcheck
+
+		return readOdsTable(rc, sheet)
+	}
+
+	return nil, fmt.Errorf("failed to open accounts file: no content.xml found")
+}
+
+// namespace of the table elements in an OpenDocument content.xml
+const odsTableNS = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+
+// readOdsTable reads the rows of the table named sheet, or the first table if sheet
+// is empty, in an OpenDocument content.xml. Repeated rows and cells are expanded,
+// trailing empty cells and rows are dropped.
+func readOdsTable(r io.Reader, sheet string) ([][]string, error) {
+	dec := xml.NewDecoder(r)
+
+	var (
+		sheets    []string
+		rows      [][]string
+		row       []string
+		rowRepeat int
+		cell      strings.Builder
+		cellRpt   int
+		inTable   bool
+		inCell    bool
+		paragraph int
+
+		// empty cells and rows are only materialized once followed by content,
+		// as files usually pad the sheet with huge repeated empty ranges
+		emptyCells int
+		emptyRows  int
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse accounts file: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				name := odsAttr(t, "name")
+				sheets = append(sheets, name)
+				inTable = sheet == "" || name == sheet
+			case !inTable:
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				row = nil
+				emptyCells = 0
+				rowRepeat = odsRepeat(t, "number-rows-repeated")
+			case t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell = true
+				paragraph = 0
+				cell.Reset()
+				cellRpt = odsRepeat(t, "number-columns-repeated")
+			case inCell && t.Name.Local == "p":
+				if paragraph > 0 {
+					cell.WriteString("\n")
+				}
+				paragraph++
+			case inCell && t.Name.Local == "s":
+				cell.WriteString(strings.Repeat(" ", odsRepeat(t, "c")))
+			case inCell && t.Name.Local == "tab":
+				cell.WriteString("\t")
+			}
+		case xml.CharData:
+			if inCell {
+				cell.Write(t)
+			}
+		case xml.EndElement:
+			if !inTable || t.Name.Space != odsTableNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "table-cell", "covered-table-cell":
+				inCell = false
+				value := cell.String()
+				if strings.TrimSpace(value) == "" {
+					emptyCells += cellRpt
+					continue
+				}
+				for ; emptyCells > 0; emptyCells-- {
+					row = append(row, "")
+				}
+				for i := 0; i < cellRpt; i++ {
+					row = append(row, value)
+				}
+			case "table-row":
+				if len(row) == 0 {
+					emptyRows += rowRepeat
+					continue
+				}
+				for ; emptyRows > 0; emptyRows-- {
+					rows = append(rows, []string{})
+				}
+				for i := 0; i < rowRepeat; i++ {
+					rows = append(rows, row)
+				}
+			case "table":
+				return rows, nil
+			}
+		}
+	}
+
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("failed to parse accounts file: no sheet found")
+	}
+	return nil, fmt.Errorf("sheet %s not found (found sheets: %s)", sheet, strings.Join(sheets, ", "))
+}
+
+// odsAttr returns the value of an attribute or an empty string
+func odsAttr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// odsRepeat returns the value of a repeat attribute, defaulting to 1
+func odsRepeat(e xml.StartElement, name string) int {
+	if n, err := strconv.Atoi(odsAttr(e, name)); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
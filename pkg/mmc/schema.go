@@ -0,0 +1,70 @@
+package mmc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Schema versions of the metadata files. Files written before the schema was
+// versioned have no SchemaVersion and are treated as version 0.
+const (
+	classroomSchemaVersion  = 1
+	assignmentSchemaVersion = 1
+)
+
+// migration upgrades the decoded JSON of a metadata file by one schema version
+type migration func(m map[string]any) error
+
+// classroomMigrations upgrade classroom.json, keyed by the version they upgrade from
+var classroomMigrations = map[int]migration{
+	0: func(m map[string]any) error { return nil },
+}
+
+// assignmentMigrations upgrade assignment.json, keyed by the version they upgrade from
+var assignmentMigrations = map[int]migration{
+	0: func(m map[string]any) error { return nil },
+}
+
+// migrate upgrades the metadata file p with the content data to the schema version
+// using the migrations. It returns the upgraded content and whether it has changed.
+// The original file is backed up before it is upgraded.
+func migrate(p string, data []byte, version int, migrations map[int]migration) ([]byte, bool, error) {
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal %s file: %v", p, err)
+	}
+
+	from := 0
+	if v, ok := m["SchemaVersion"].(float64); ok {
+		from = int(v)
+	}
+	if from > version {
+		return nil, false, fmt.Errorf("%s file has schema version %d, which is newer than the supported version %d: upgrade gh mmc", p, from, version)
+	}
+	if from == version {
+		return data, false, nil
+	}
+
+	for v := from; v < version; v++ {
+		up, ok := migrations[v]
+		if !ok {
+			return nil, false, fmt.Errorf("failed to migrate %s file: no migration from schema version %d", p, v)
+		}
+		if err := up(m); err != nil {
+			return nil, false, fmt.Errorf("failed to migrate %s file from schema version %d: %v", p, v, err)
+		}
+		m["SchemaVersion"] = v + 1
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", p, from)
+	if err := writeFileAtomic(backup, data); err != nil {
+		return nil, false, fmt.Errorf("failed to back up %s file: %v", p, err)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal %s file: %v", p, err)
+	}
+
+	return data, true, nil
+}
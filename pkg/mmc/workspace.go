@@ -0,0 +1,130 @@
+package mmc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const workspaceFile = "workspace.json"
+
+var (
+	ErrWorkspaceNotFound = errors.New("no workspace found: run `gh mmc workspace init` in the folder containing the classroom folders")
+)
+
+// workspace marks a folder containing several classroom folders
+type workspace struct {
+	SchemaVersion int
+}
+
+// InitWorkspace marks folder as a workspace containing classroom folders
+func InitWorkspace(folder string) error {
+	f := filepath.Join(folder, mmcFolder)
+	if _, err := os.Stat(filepath.Join(f, classroomFile)); err == nil {
+		return fmt.Errorf("%s is a classroom folder: initialize the workspace in the folder containing the classroom folders", folder)
+	}
+	if err := os.MkdirAll(f, 0755); err != nil {
+		return fmt.Errorf("failed to create %s directory: %v", f, err)
+	}
+
+	j, err := json.MarshalIndent(workspace{SchemaVersion: 1}, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal workspace: %v", err)
+	}
+
+	return writeFileAtomic(filepath.Join(f, workspaceFile), j)
+}
+
+// FindWorkspaceFolder searches upwards from the current directory to find the workspace folder
+func FindWorkspaceFolder() (string, error) {
+	currentDir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	for {
+		p := filepath.Join(currentDir, mmcFolder, workspaceFile)
+		if _, err := os.Stat(p); err == nil {
+			return currentDir, nil
+		}
+
+		parentDir := filepath.Dir(currentDir)
+		if parentDir == currentDir {
+			return "", ErrWorkspaceNotFound
+		}
+
+		currentDir = parentDir
+	}
+}
+
+// ListClassroomFolders returns the classroom folders below the workspace folder, sorted
+// by path. Hidden folders and the folders inside classroom folders are not searched.
+func ListClassroomFolders(workspaceFolder string) ([]string, error) {
+	var folders []string
+	err := filepath.WalkDir(workspaceFolder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != workspaceFolder && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, mmcFolder, classroomFile)); err == nil {
+			folders = append(folders, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search classroom folders: %v", err)
+	}
+
+	sort.Strings(folders)
+	return folders, nil
+}
+
+// WorkspaceClassroomFolders returns the classroom folders of the workspace the current
+// directory belongs to
+func WorkspaceClassroomFolders() ([]string, error) {
+	workspaceFolder, err := FindWorkspaceFolder()
+	if err != nil {
+		return nil, err
+	}
+
+	folders, err := ListClassroomFolders(workspaceFolder)
+	if err != nil {
+		return nil, err
+	}
+	if len(folders) == 0 {
+		return nil, fmt.Errorf("no classroom folders found in workspace %s", workspaceFolder)
+	}
+	return folders, nil
+}
+
+// ListAssignmentFolders returns the assignment folders directly below the classroom
+// folder, sorted by name
+func ListAssignmentFolders(classroomFolder string) ([]string, error) {
+	entries, err := os.ReadDir(classroomFolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read classroom directory: %v", err)
+	}
+
+	var folders []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		folder := filepath.Join(classroomFolder, e.Name())
+		if _, err := os.Stat(filepath.Join(folder, mmcFolder, assigmentFile)); err == nil {
+			folders = append(folders, folder)
+		}
+	}
+	return folders, nil
+}